@@ -15,14 +15,17 @@ import (
 )
 
 var config = Configuration{
-	Homepage:    "https://septs.blog/posts/rsp-dump/",
-	HostPattern: regexp.MustCompile(`^(?P<issuer>[a-f0-9]{6,40})\.rsp\.`),
-	SMTPPort:    587,
-	SMTPHeaders: make(map[string][]string),
+	Homepage:         "https://septs.blog/posts/rsp-dump/",
+	HostPattern:      regexp.MustCompile(`^(?P<issuer>[a-z0-9-]{2,40})(?:\.(?P<smdp>[a-z0-9-]{1,63}))?\.rsp\.`),
+	PreferProduction: true,
+	SMTPPort:         587,
+	SMTPHeaders:      make(map[string][]string),
 }
 
 var smtpClient *mail.Dialer
 
+var ciBundle dump.CIBundle
+
 func init() {
 	if fp, err := os.Open("rsp-config.json"); err != nil {
 		log.Fatalln(err)
@@ -40,21 +43,44 @@ func main() {
 	log.SetFlags(0)
 	http.DefaultTransport.(*http.Transport).TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
 	handler := &dump.Handler{
-		Homepage:       config.Homepage,
-		Client:         http.DefaultClient,
-		Issuers:        mustRSPRegistry(),
-		HostPattern:    config.HostPattern,
+		Homepage:    config.Homepage,
+		Client:      http.DefaultClient,
+		HostPattern: config.HostPattern,
+		Policy: dump.IssuerPolicy{
+			Priority:         config.IssuerPriority,
+			PreferProduction: config.PreferProduction,
+		},
 		OnAuthenClient: onAuthenClient,
 	}
+	if config.CIBundle != "" {
+		var err error
+		if ciBundle, err = dump.LoadCIBundle(config.CIBundle); err != nil {
+			log.Panicln(err)
+		}
+		handler.CIs = ciBundle
+	}
+	if err := dump.LoadCatalogs(config.CICatalog, config.EUMCatalog, config.SASCatalog); err != nil {
+		log.Panicln(err)
+	}
+	if config.SigningCert != "" && config.SigningKey != "" {
+		signer, err := dump.NewLocalSigner(config.SigningCert, config.SigningKey)
+		if err != nil {
+			log.Panicln(err)
+		}
+		handler.Signer = signer
+	} else {
+		handler.Issuers = mustRSPRegistry()
+	}
 	lambda.Start(httpadapter.New(handler).ProxyWithContext)
 }
 
-func mustRSPRegistry() (issuers map[string][]string) {
+func mustRSPRegistry() (issuers dump.Registry) {
 	fp, err := os.Open("rsp-registry.json")
 	if err != nil {
 		panic(err)
 	}
-	if err = json.NewDecoder(fp).Decode(&issuers); err != nil {
+	defer fp.Close()
+	if issuers, err = dump.ReadRegistry(fp); err != nil {
 		panic(err)
 	}
 	return
@@ -9,11 +9,15 @@ import (
 	"strings"
 )
 
-func onAuthenClient(response *bertlv.TLV) (err error) {
+func onAuthenClient(session *dump.Session, response *bertlv.TLV) (err error) {
 	var report dump.Report
 	if err = report.UnmarshalBerTLV(response); err != nil {
 		return
 	}
+	if err = report.SetSession(session); err != nil {
+		return
+	}
+	report.Verify(ciBundle)
 	message := dump.NewMailMessage(&report, config.HostTemplate)
 	message.SetHeaders(config.SMTPHeaders)
 	if !strings.Contains(report.MatchingID, "@") {
@@ -5,12 +5,20 @@ import (
 )
 
 type Configuration struct {
-	Homepage     string              `json:"homepage_url"`
-	HostPattern  *regexp.Regexp      `json:"host_pattern"`
-	HostTemplate string              `json:"host_template"`
-	SMTPHost     string              `json:"smtp_host"`
-	SMTPPort     uint16              `json:"smtp_port"`
-	SMTPUsername string              `json:"smtp_username"`
-	SMTPPassword string              `json:"smtp_password"`
-	SMTPHeaders  map[string][]string `json:"smtp_headers"`
+	Homepage         string              `json:"homepage_url"`
+	HostPattern      *regexp.Regexp      `json:"host_pattern"`
+	HostTemplate     string              `json:"host_template"`
+	IssuerPriority   []string            `json:"issuer_priority"`
+	PreferProduction bool                `json:"prefer_production"`
+	CIBundle         string              `json:"ci_bundle_file"`
+	CICatalog        string              `json:"ci_catalog_file"`
+	EUMCatalog       string              `json:"eum_catalog_file"`
+	SASCatalog       string              `json:"sas_catalog_file"`
+	SigningCert      string              `json:"signing_cert_file"`
+	SigningKey       string              `json:"signing_key_file"`
+	SMTPHost         string              `json:"smtp_host"`
+	SMTPPort         uint16              `json:"smtp_port"`
+	SMTPUsername     string              `json:"smtp_username"`
+	SMTPPassword     string              `json:"smtp_password"`
+	SMTPHeaders      map[string][]string `json:"smtp_headers"`
 }
@@ -4,6 +4,7 @@ import (
 	"crypto/tls"
 	_ "embed"
 	"encoding/json"
+	"errors"
 	"flag"
 	"github.com/CursedHardware/go-rsp-dump/rsp/dump"
 	"gopkg.in/mail.v2"
@@ -13,46 +14,127 @@ import (
 	"net/http"
 	"os"
 	"regexp"
+	"slices"
 	"strconv"
+	"time"
 )
 
-var smtpClient *mail.Dialer
+var configFile string
 
-var config = Configuration{
-	Listen:      "localhost:33000",
-	Homepage:    "https://septs.blog/posts/rsp-dump/",
-	HostPattern: regexp.MustCompile(`^(?P<issuer>[a-f0-9]{6,40})\.rsp\.`),
-	LogFile:     "rsp-report.log",
-	SMTPPort:    587,
-	SMTPHeaders: make(map[string][]string),
+var config *Configuration
+
+var defaults = Configuration{
+	Listen:           "localhost:33000",
+	Homepage:         "https://septs.blog/posts/rsp-dump/",
+	HostPattern:      regexp.MustCompile(`^(?P<issuer>[a-z0-9-]{2,40})(?:\.(?P<smdp>[a-z0-9-]{1,63}))?\.rsp\.`),
+	LogFile:          "rsp-report.log",
+	PreferProduction: true,
+	RegistryFile:     "rsp-registry.json",
+	RegistrySource:   dump.RegistryTableURL,
+	RegistryExclude: []string{
+		"rsp.simhub.cn",
+		"rsp.esim.whty.com.cn",
+		"rsp.esim.me:8083",
+		"www.esimtest.chinattl.cn",
+		"smdp-plus-0.eu.cd.rsp.kigen.com",
+	},
+	ProbeFile:      "rsp-probe.json",
+	ProbeInterval:  3600,
+	ReloadInterval: 10,
+	SMTPPort:       587,
 }
 
 func init() {
-	var configFile string
 	flag.StringVar(&configFile, "config-file", "rsp-config.json", "Configuration file path")
 	flag.Parse()
-	if fp, err := os.Open(configFile); err != nil {
-		log.Fatalln(err)
-	} else if err = json.NewDecoder(fp).Decode(&config); err != nil {
-		log.Fatalln(err)
+	var err error
+	if config, err = loadConfiguration(configFile); err != nil {
+		if flag.Arg(0) != "registry" || !errors.Is(err, os.ErrNotExist) {
+			log.Fatalln(err)
+		}
+		config = &defaults
 	}
-	if _, ok := config.SMTPHeaders["From"]; !ok {
-		config.SMTPHeaders["From"] = []string{config.SMTPUsername}
+	state.Store(&reloadState{config: config, smtpClient: newSMTPClient(config)})
+}
+
+func loadConfiguration(name string) (*Configuration, error) {
+	loaded := defaults
+	loaded.HostPattern = nil
+	loaded.RegistryExclude = slices.Clone(defaults.RegistryExclude)
+	loaded.SMTPHeaders = make(map[string][]string)
+	fp, err := os.Open(name)
+	if err != nil {
+		return nil, err
 	}
-	smtpClient = mail.NewDialer(config.SMTPHost, int(config.SMTPPort), config.SMTPUsername, config.SMTPPassword)
+	defer fp.Close()
+	if err = json.NewDecoder(fp).Decode(&loaded); err != nil {
+		return nil, err
+	}
+	if loaded.HostPattern == nil {
+		loaded.HostPattern = defaults.HostPattern
+	}
+	if _, ok := loaded.SMTPHeaders["From"]; !ok {
+		loaded.SMTPHeaders["From"] = []string{loaded.SMTPUsername}
+	}
+	return &loaded, nil
+}
+
+func issuerPolicy(config *Configuration) dump.IssuerPolicy {
+	return dump.IssuerPolicy{Priority: config.IssuerPriority, PreferProduction: config.PreferProduction}
+}
+
+func newSMTPClient(config *Configuration) *mail.Dialer {
+	smtpClient := mail.NewDialer(config.SMTPHost, int(config.SMTPPort), config.SMTPUsername, config.SMTPPassword)
 	smtpClient.StartTLSPolicy = mail.MandatoryStartTLS
+	return smtpClient
 }
 
 func main() {
+	if flag.Arg(0) == "registry" {
+		runRegistry(flag.Args()[1:])
+		return
+	}
 	log.Println("Starting")
 	http.DefaultTransport.(*http.Transport).TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
 	handler := &dump.Handler{
 		Homepage:       config.Homepage,
 		Client:         http.DefaultClient,
-		Issuers:        mustRSPRegistry(),
 		HostPattern:    config.HostPattern,
+		Policy:         issuerPolicy(config),
+		Sessions:       mustSessionStore(),
 		OnAuthenClient: onAuthenClient,
 	}
+	if config.CIBundle != "" {
+		bundle, err := dump.LoadCIBundle(config.CIBundle)
+		if err != nil {
+			log.Panicln(err)
+		}
+		handler.CIs = bundle
+		state.Store(&reloadState{config: config, smtpClient: newSMTPClient(config), ciBundle: bundle})
+	}
+	if err := dump.LoadCatalogs(config.CICatalog, config.EUMCatalog, config.SASCatalog); err != nil {
+		log.Panicln(err)
+	}
+	if config.SigningCert != "" && config.SigningKey != "" {
+		signer, err := dump.NewLocalSigner(config.SigningCert, config.SigningKey)
+		if err != nil {
+			log.Panicln(err)
+		}
+		handler.Signer = signer
+	} else {
+		handler.Issuers = mustRSPRegistry()
+		if config.ProbeInterval > 0 {
+			go watchProbe(handler)
+		}
+	}
+	if config.MailTemplate != "" {
+		tpl, err := readMailTemplate(config.MailTemplate)
+		if err != nil {
+			log.Panicln(err)
+		}
+		dump.SetMailTemplate(tpl)
+	}
+	go watchReload(handler)
 	if logFile, err := os.OpenFile(config.LogFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666); err == nil {
 		log.SetOutput(io.MultiWriter(os.Stdout, logFile))
 	}
@@ -78,13 +160,31 @@ func main() {
 	}
 }
 
-func mustRSPRegistry() (issuers map[string][]string) {
-	fp, err := os.Open("rsp-registry.json")
+func mustRSPRegistry() dump.Registry {
+	registry, err := readRegistry(config.RegistryFile)
 	if err != nil {
 		panic(err)
 	}
-	if err = json.NewDecoder(fp).Decode(&issuers); err != nil {
+	return registry
+}
+
+func readRegistry(name string) (dump.Registry, error) {
+	fp, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer fp.Close()
+	return dump.ReadRegistry(fp)
+}
+
+func mustSessionStore() dump.SessionStore {
+	ttl := time.Duration(config.SessionTTL) * time.Second
+	if config.SessionFile == "" {
+		return dump.NewMemorySessionStore(ttl)
+	}
+	store, err := dump.NewFileSessionStore(config.SessionFile, ttl)
+	if err != nil {
 		panic(err)
 	}
-	return
+	return store
 }
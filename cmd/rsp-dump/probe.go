@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"github.com/CursedHardware/go-rsp-dump/rsp/dump"
+	"log"
+	"net/http"
+	"os"
+	"time"
+)
+
+func watchProbe(handler *dump.Handler) {
+	var last time.Time
+	if report, err := readProbeReport(config.ProbeFile); err == nil {
+		handler.SetProbeReport(report)
+		last = report.CheckedAt
+	}
+	for {
+		current := state.Load().config
+		if current.ProbeInterval == 0 {
+			time.Sleep(time.Minute)
+			continue
+		}
+		time.Sleep(time.Until(last.Add(time.Duration(current.ProbeInterval) * time.Second)))
+		report, err := probeRegistry(current.RegistryFile, current.ProbeFile)
+		last = time.Now()
+		if err != nil {
+			log.Println("Probe failed:", err)
+			continue
+		}
+		handler.SetProbeReport(report)
+		var failures int
+		for _, result := range report.Results {
+			if !result.OK() {
+				failures++
+			}
+		}
+		log.Println("Probe:", len(report.Results), "hosts checked,", failures, "failed")
+	}
+}
+
+func probeRegistry(registryFile, statusFile string) (*dump.ProbeReport, error) {
+	registry, err := readRegistry(registryFile)
+	if err != nil {
+		return nil, err
+	}
+	prober := &dump.Prober{
+		Client: &http.Client{
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}},
+		},
+	}
+	report := prober.Probe(context.Background(), registry)
+	if statusFile == "" {
+		return report, nil
+	}
+	var buf bytes.Buffer
+	if _, err = report.WriteTo(&buf); err != nil {
+		return nil, err
+	}
+	temporary := statusFile + ".tmp"
+	if err = os.WriteFile(temporary, buf.Bytes(), 0644); err != nil {
+		return nil, err
+	}
+	return report, os.Rename(temporary, statusFile)
+}
+
+func readProbeReport(name string) (*dump.ProbeReport, error) {
+	fp, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer fp.Close()
+	return dump.ReadProbeReport(fp)
+}
+
+func formatProbeResult(result dump.ProbeResult) string {
+	status := "ok"
+	if !result.OK() {
+		status = "fail"
+	}
+	line := fmt.Sprintf("%-4s %s %s %s", status, result.Issuer, result.Host, result.Latency.Round(time.Millisecond))
+	if result.SubjectCode != "" {
+		line += fmt.Sprintf(" [%s/%s]", result.SubjectCode, result.ReasonCode)
+	}
+	if result.Error != "" {
+		line += " " + result.Error
+	}
+	return line
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"github.com/CursedHardware/go-rsp-dump/rsp/dump"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+)
+
+func runRegistry(args []string) {
+	if len(args) == 0 {
+		log.Fatalln("usage: rsp-dump registry <sync|diff|probe> [-source <file|url>] [-output <file>] [-status <file>]")
+	}
+	flags := flag.NewFlagSet("registry "+args[0], flag.ExitOnError)
+	source := flags.String("source", config.RegistrySource, "Registry CSV file path or URL")
+	output := flags.String("output", config.RegistryFile, "Registry JSON file path")
+	status := flags.String("status", config.ProbeFile, "Probe status file path")
+	_ = flags.Parse(args[1:])
+	if args[0] == "probe" {
+		report, err := probeRegistry(*output, *status)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		for _, result := range report.Results {
+			fmt.Println(formatProbeResult(result))
+		}
+		return
+	}
+	next, err := fetchRegistry(*source)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	current := make(dump.Registry)
+	if fp, err := os.Open(*output); err == nil {
+		current, err = dump.ReadRegistry(fp)
+		_ = fp.Close()
+		if err != nil {
+			log.Fatalln(err)
+		}
+	}
+	switch args[0] {
+	case "sync":
+		next.Inherit(current)
+		var buf bytes.Buffer
+		if _, err = next.WriteTo(&buf); err != nil {
+			log.Fatalln(err)
+		}
+		if err = os.WriteFile(*output, buf.Bytes(), 0644); err != nil {
+			log.Fatalln(err)
+		}
+		log.Println("Registry:", len(next), "issuers written to", *output)
+	case "diff":
+		for _, change := range current.Diff(next) {
+			fmt.Println(change)
+		}
+	default:
+		log.Fatalln("unknown registry command:", args[0])
+	}
+}
+
+func fetchRegistry(source string) (dump.Registry, error) {
+	var r io.Reader
+	if strings.HasPrefix(source, "https://") || strings.HasPrefix(source, "http://") {
+		response, err := http.Get(source)
+		if err != nil {
+			return nil, err
+		}
+		defer response.Body.Close()
+		if response.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("registry: %s: %s", source, response.Status)
+		}
+		r = response.Body
+	} else {
+		fp, err := os.Open(source)
+		if err != nil {
+			return nil, err
+		}
+		defer fp.Close()
+		r = fp
+	}
+	registry, warnings, err := dump.ParseRegistryCSV(r, dump.RegistryFilter{
+		Include: config.RegistryInclude,
+		Exclude: config.RegistryExclude,
+	})
+	for _, warning := range warnings {
+		log.Println("Skipped:", warning)
+	}
+	return registry, err
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"errors"
+	"github.com/CursedHardware/go-rsp-dump/rsp/dump"
+	"gopkg.in/mail.v2"
+	"html/template"
+	"log"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+type reloadState struct {
+	config     *Configuration
+	smtpClient *mail.Dialer
+	ciBundle   dump.CIBundle
+}
+
+var state atomic.Pointer[reloadState]
+
+func watchReload(handler *dump.Handler) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	var ticker <-chan time.Time
+	if config.ReloadInterval > 0 {
+		ticker = time.Tick(time.Duration(config.ReloadInterval) * time.Second)
+	}
+	modified := watchedModTime()
+	for {
+		select {
+		case <-signals:
+			log.Println("Reload: SIGHUP")
+		case <-ticker:
+			if current := watchedModTime(); current.Equal(modified) {
+				continue
+			} else {
+				modified = current
+			}
+			log.Println("Reload: file changed")
+		}
+		if err := reload(handler); err != nil {
+			log.Println("Reload failed, keeping previous configuration:", err)
+		} else {
+			log.Println("Reload: done")
+		}
+	}
+}
+
+func reload(handler *dump.Handler) (err error) {
+	next, err := loadConfiguration(configFile)
+	if err != nil {
+		return
+	}
+	var registry dump.Registry
+	if handler.Signer == nil {
+		if registry, err = readRegistry(next.RegistryFile); err != nil {
+			return
+		}
+	}
+	var report *dump.ProbeReport
+	if handler.Signer == nil && next.ProbeFile != "" {
+		if report, err = readProbeReport(next.ProbeFile); errors.Is(err, os.ErrNotExist) {
+			err = nil
+		} else if err != nil {
+			return
+		}
+	}
+	var bundle dump.CIBundle
+	if next.CIBundle != "" {
+		if bundle, err = dump.LoadCIBundle(next.CIBundle); err != nil {
+			return
+		}
+	}
+	var tpl *template.Template
+	if next.MailTemplate != "" {
+		if tpl, err = readMailTemplate(next.MailTemplate); err != nil {
+			return
+		}
+	}
+	if err = dump.LoadCatalogs(next.CICatalog, next.EUMCatalog, next.SASCatalog); err != nil {
+		return
+	}
+	handler.Update(registry, next.HostPattern, issuerPolicy(next))
+	handler.SetCIBundle(bundle)
+	if report != nil {
+		handler.SetProbeReport(report)
+	}
+	dump.SetMailTemplate(tpl)
+	state.Store(&reloadState{config: next, smtpClient: newSMTPClient(next), ciBundle: bundle})
+	return
+}
+
+func readMailTemplate(name string) (*template.Template, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	return dump.ParseMailTemplate(string(data))
+}
+
+// latest modification time among the configuration, registry, CI bundle, CI, EUM and SAS catalogs and mail template files
+func watchedModTime() (latest time.Time) {
+	current := state.Load().config
+	for _, name := range []string{configFile, current.RegistryFile, current.CIBundle, current.CICatalog, current.EUMCatalog, current.SASCatalog, current.MailTemplate} {
+		if name == "" {
+			continue
+		}
+		if info, err := os.Stat(name); err == nil && info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return
+}
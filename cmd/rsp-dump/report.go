@@ -9,13 +9,18 @@ import (
 	"strings"
 )
 
-func onAuthenClient(response *bertlv.TLV) (err error) {
+func onAuthenClient(session *dump.Session, response *bertlv.TLV) (err error) {
 	var report dump.Report
 	if err = report.UnmarshalBerTLV(response); err != nil {
 		return
 	}
-	message := dump.NewMailMessage(&report, config.HostTemplate)
-	message.SetHeaders(config.SMTPHeaders)
+	if err = report.SetSession(session); err != nil {
+		return
+	}
+	current := state.Load()
+	report.Verify(current.ciBundle)
+	message := dump.NewMailMessage(&report, current.config.HostTemplate)
+	message.SetHeaders(current.config.SMTPHeaders)
 	if !strings.Contains(report.MatchingID, "@") {
 		decoded, _ := base64.RawStdEncoding.DecodeString(report.MatchingID)
 		if bytes.ContainsRune(decoded, '@') {
@@ -27,5 +32,5 @@ func onAuthenClient(response *bertlv.TLV) (err error) {
 	} else {
 		return errors.New("no recipient, please set email address in matching-id")
 	}
-	return smtpClient.DialAndSend(message)
+	return current.smtpClient.DialAndSend(message)
 }
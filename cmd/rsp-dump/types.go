@@ -5,16 +5,34 @@ import (
 )
 
 type Configuration struct {
-	Listen       string              `json:"listen"`
-	Homepage     string              `json:"homepage_url"`
-	HostPattern  *regexp.Regexp      `json:"host_pattern"`
-	HostTemplate string              `json:"host_template"`
-	CertFile     string              `json:"cert_file"`
-	KeyFile      string              `json:"key_file"`
-	LogFile      string              `json:"log_file"`
-	SMTPHost     string              `json:"smtp_host"`
-	SMTPPort     uint16              `json:"smtp_port"`
-	SMTPUsername string              `json:"smtp_username"`
-	SMTPPassword string              `json:"smtp_password"`
-	SMTPHeaders  map[string][]string `json:"smtp_headers"`
+	Listen           string              `json:"listen"`
+	Homepage         string              `json:"homepage_url"`
+	HostPattern      *regexp.Regexp      `json:"host_pattern"`
+	HostTemplate     string              `json:"host_template"`
+	IssuerPriority   []string            `json:"issuer_priority"`
+	PreferProduction bool                `json:"prefer_production"`
+	CIBundle         string              `json:"ci_bundle_file"`
+	CICatalog        string              `json:"ci_catalog_file"`
+	EUMCatalog       string              `json:"eum_catalog_file"`
+	SASCatalog       string              `json:"sas_catalog_file"`
+	CertFile         string              `json:"cert_file"`
+	KeyFile          string              `json:"key_file"`
+	SigningCert      string              `json:"signing_cert_file"`
+	SigningKey       string              `json:"signing_key_file"`
+	LogFile          string              `json:"log_file"`
+	SessionFile      string              `json:"session_file"`
+	SessionTTL       uint32              `json:"session_ttl"`
+	RegistryFile     string              `json:"registry_file"`
+	RegistrySource   string              `json:"registry_source"`
+	RegistryInclude  []string            `json:"registry_include"`
+	RegistryExclude  []string            `json:"registry_exclude"`
+	ProbeFile        string              `json:"probe_file"`
+	ProbeInterval    uint32              `json:"probe_interval"`
+	MailTemplate     string              `json:"mail_template_file"`
+	ReloadInterval   uint32              `json:"reload_interval"`
+	SMTPHost         string              `json:"smtp_host"`
+	SMTPPort         uint16              `json:"smtp_port"`
+	SMTPUsername     string              `json:"smtp_username"`
+	SMTPPassword     string              `json:"smtp_password"`
+	SMTPHeaders      map[string][]string `json:"smtp_headers"`
 }
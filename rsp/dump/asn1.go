@@ -0,0 +1,94 @@
+package dump
+
+import (
+	"encoding/hex"
+	. "github.com/CursedHardware/go-rsp-dump/rsp/types"
+	. "github.com/euicc-go/bertlv"
+	"log"
+)
+
+func decodeInitAuthenASN1(tlv *TLV) (*InitAuthenRequest, error) {
+	request := &InitAuthenRequest{
+		Challenge:        valueOf(tlv.First(Tag{0x81})),
+		Address:          string(valueOf(tlv.First(Tag{0x83}))),
+		Info1:            tlv.First(Tag{0xBF, 0x20}),
+		LPARSPCapability: tlv.First(Tag{0x85}),
+	}
+	if request.Challenge == nil || request.Info1 == nil {
+		return nil, errMissingElement
+	}
+	return request, nil
+}
+
+func encodeInitAuthenASN1(response *InitAuthenResponse, err error) *TLV {
+	tag := Tag{0xBF, 0x39}
+	if err != nil {
+		return asn1Error(tag, "ASN1.InitiateAuthenticationResponse", err, initAuthenErrorCodes)
+	}
+	transactionId, err := hex.DecodeString(response.TransactionId)
+	if err != nil {
+		err = newError("8.10.1", "3.9", "InitiateAuthenticationResponse: invalid transaction id (%s)", response.TransactionId)
+		return asn1Error(tag, "ASN1.InitiateAuthenticationResponse", err, initAuthenErrorCodes)
+	}
+	ok := NewChildren(
+		Tag{0xA0},
+		NewValue(Tag{0x80}, transactionId),
+		response.Signed1,
+		response.Signature1,
+		response.UsedIssuer,
+		response.Certificate,
+	)
+	// otherCertsInChain [1] CertificateChain OPTIONAL
+	if len(response.OtherCertsInChain) > 0 {
+		ok.Children = append(ok.Children, NewChildren(Tag{0xA1}, response.OtherCertsInChain...))
+	}
+	return NewChildren(tag, ok)
+}
+
+func decodeAuthenClientASN1(tlv *TLV) (*AuthenClientRequest, error) {
+	request := &AuthenClientRequest{
+		TransactionId: hex.EncodeToString(valueOf(tlv.First(Tag{0x80}))),
+		Response:      tlv.First(Tag{0xBF, 0x38}),
+	}
+	if request.TransactionId == "" || request.Response == nil {
+		return nil, errMissingElement
+	}
+	return request, nil
+}
+
+func encodeAuthenClientASN1(_ *GeneralResponse, err error) *TLV {
+	return asn1Error(Tag{0xBF, 0x3B}, "ASN1.AuthenticateClientResponse", err, authenClientErrorCodes)
+}
+
+func decodeCancelSessionASN1(tlv *TLV) (*CancelSessionRequest, error) {
+	request := &CancelSessionRequest{
+		TransactionId: hex.EncodeToString(valueOf(tlv.First(Tag{0x80}))),
+		Response:      tlv.First(Tag{0xBF, 0x41}),
+	}
+	if request.TransactionId == "" || request.Response == nil {
+		return nil, errMissingElement
+	}
+	return request, nil
+}
+
+func encodeCancelSessionASN1(_ *GeneralResponse, err error) *TLV {
+	tag := Tag{0xBF, 0x41}
+	if err != nil {
+		return asn1Error(tag, "ASN1.CancelSessionResponse", err, cancelSessionErrorCodes)
+	}
+	return NewChildren(tag, NewChildren(Tag{0xA0}))
+}
+
+func asn1Error(tag Tag, function string, err error, codes map[string]byte) *TLV {
+	_err := toError(err)
+	code := errorCode(_err, codes)
+	log.Println(function, "Code:", code, "SubjectCode:", _err.SubjectCode, "ReasonCode:", _err.ReasonCode, "Message:", _err.Message)
+	return NewChildren(tag, NewValue(Tag{0x81}, []byte{code}))
+}
+
+func valueOf(tlv *TLV) []byte {
+	if tlv == nil {
+		return nil
+	}
+	return tlv.Value
+}
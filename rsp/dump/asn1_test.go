@@ -0,0 +1,76 @@
+package dump
+
+import (
+	"bytes"
+	"encoding/json"
+	. "github.com/CursedHardware/go-rsp-dump/rsp/types"
+	. "github.com/euicc-go/bertlv"
+	"testing"
+)
+
+func TestInitAuthenOtherCertsInChain(t *testing.T) {
+	// stand-ins for the SubCA certificates of an SGP.22 v3 chain
+	chain := []*TLV{
+		NewChildren(Tag{0x30}, NewValue(Tag{0x02}, []byte{0x01})),
+		NewChildren(Tag{0x30}, NewValue(Tag{0x02}, []byte{0x02})),
+	}
+	response := &InitAuthenResponse{
+		Header:            ExecutedSuccess.Header(nil),
+		TransactionId:     "0A0B",
+		Signed1:           NewChildren(Tag{0x30}, NewValue(Tag{0x80}, []byte{0x0A, 0x0B})),
+		Signature1:        NewValue(Tag{0x5F, 0x37}, []byte{0x01, 0x02}),
+		UsedIssuer:        NewValue(Tag{0x04}, []byte{0x81, 0x37}),
+		Certificate:       NewChildren(Tag{0x30}, NewValue(Tag{0x02}, []byte{0x03})),
+		OtherCertsInChain: chain,
+	}
+	equal := func(t *testing.T, got []*TLV) {
+		t.Helper()
+		if len(got) != len(chain) {
+			t.Fatalf("otherCertsInChain has %d certificates, want %d", len(got), len(chain))
+		}
+		for index := range chain {
+			if !bytes.Equal(got[index].Bytes(), chain[index].Bytes()) {
+				t.Fatalf("otherCertsInChain[%d] = %X, want %X", index, got[index].Bytes(), chain[index].Bytes())
+			}
+		}
+	}
+
+	t.Run("json", func(t *testing.T) {
+		data, err := json.Marshal(response)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoded := new(InitAuthenResponse)
+		if err = json.Unmarshal(data, decoded); err != nil {
+			t.Fatal(err)
+		}
+		equal(t, decoded.OtherCertsInChain)
+	})
+
+	t.Run("asn1", func(t *testing.T) {
+		data, err := encodeInitAuthenASN1(response, nil).MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoded := new(TLV)
+		if err = decoded.UnmarshalBinary(data); err != nil {
+			t.Fatal(err)
+		}
+		others := decoded.First(Tag{0xA0}).First(Tag{0xA1})
+		if others == nil {
+			t.Fatal("otherCertsInChain [1] missing")
+		}
+		equal(t, others.Children)
+	})
+
+	t.Run("omitted", func(t *testing.T) {
+		v2 := *response
+		v2.OtherCertsInChain = nil
+		if data, _ := json.Marshal(&v2); bytes.Contains(data, []byte("otherCertsInChain")) {
+			t.Fatalf("v2 response carries otherCertsInChain: %s", data)
+		}
+		if encodeInitAuthenASN1(&v2, nil).First(Tag{0xA0}).First(Tag{0xA1}) != nil {
+			t.Fatal("v2 response carries otherCertsInChain [1]")
+		}
+	})
+}
@@ -0,0 +1,155 @@
+package dump
+
+import (
+	"errors"
+	"math/big"
+)
+
+// weierstrass is a short Weierstrass curve y² = x³ + ax + b over a prime field.
+// crypto/elliptic only implements a = -3, the Brainpool curves of RFC 5639 need the generic formulas.
+// The arithmetic is only used to verify signatures, it is not constant time.
+type weierstrass struct {
+	Name       string
+	P, N, A, B *big.Int
+	Gx, Gy     *big.Int
+	ByteSize   int
+}
+
+// weierstrassKey is a public key on a curve that crypto/ecdsa does not support
+type weierstrassKey struct {
+	Curve *weierstrass
+	X, Y  *big.Int
+}
+
+func hexInt(text string) *big.Int {
+	value, ok := new(big.Int).SetString(text, 16)
+	if !ok {
+		panic("invalid curve constant " + text)
+	}
+	return value
+}
+
+// brainpoolP256r1 from RFC 5639 section 3.4
+var brainpoolP256r1 = &weierstrass{
+	Name:     "brainpoolP256r1",
+	P:        hexInt("A9FB57DBA1EEA9BC3E660A909D838D726E3BF623D52620282013481D1F6E5377"),
+	A:        hexInt("7D5A0975FC2C3057EEF67530417AFFE7FB8055C126DC5C6CE94A4B44F330B5D9"),
+	B:        hexInt("26DC5C6CE94A4B44F330B5D9BBD77CBF958416295CF7E1CE6BCCDC18FF8C07B6"),
+	Gx:       hexInt("8BD2AEB9CB7E57CB2C4B482FFC81B7AFB9DE27E1E3BD23C23A4453BD9ACE3262"),
+	Gy:       hexInt("547EF835C3DAC4FD97F8461A14611DC9C27745132DED8E545C1D54C72F046997"),
+	N:        hexInt("A9FB57DBA1EEA9BC3E660A909D838D718C397AA3B561A6F7901E0E82974856A7"),
+	ByteSize: 32,
+}
+
+// weierstrassCurves by the OID of the named curve in the SubjectPublicKeyInfo parameters
+var weierstrassCurves = map[string]*weierstrass{
+	"1.3.36.3.3.2.8.1.1.7": brainpoolP256r1,
+}
+
+func (c *weierstrass) isOnCurve(x, y *big.Int) bool {
+	if x.Sign() < 0 || x.Cmp(c.P) >= 0 || y.Sign() < 0 || y.Cmp(c.P) >= 0 {
+		return false
+	}
+	left := new(big.Int).Mul(y, y)
+	left.Mod(left, c.P)
+	right := new(big.Int).Mul(x, x)
+	right.Add(right, c.A)
+	right.Mul(right, x)
+	right.Add(right, c.B)
+	right.Mod(right, c.P)
+	return left.Cmp(right) == 0
+}
+
+// unmarshal reads an uncompressed point of SEC 1 section 2.3.4
+func (c *weierstrass) unmarshal(data []byte) (*weierstrassKey, error) {
+	if len(data) != 1+2*c.ByteSize || data[0] != 4 {
+		return nil, errors.New("unsupported point encoding")
+	}
+	x := new(big.Int).SetBytes(data[1 : 1+c.ByteSize])
+	y := new(big.Int).SetBytes(data[1+c.ByteSize:])
+	if !c.isOnCurve(x, y) {
+		return nil, errors.New("point is not on the curve " + c.Name)
+	}
+	return &weierstrassKey{Curve: c, X: x, Y: y}, nil
+}
+
+// add is the affine point addition, a nil x is the point at infinity
+func (c *weierstrass) add(x1, y1, x2, y2 *big.Int) (x3, y3 *big.Int) {
+	switch {
+	case x1 == nil:
+		return x2, y2
+	case x2 == nil:
+		return x1, y1
+	case x1.Cmp(x2) == 0:
+		if y1.Cmp(y2) == 0 && y1.Sign() != 0 {
+			return c.double(x1, y1)
+		}
+		return nil, nil
+	}
+	slope := new(big.Int).Sub(x2, x1)
+	slope.ModInverse(slope.Mod(slope, c.P), c.P)
+	slope.Mul(slope, new(big.Int).Sub(y2, y1))
+	slope.Mod(slope, c.P)
+	return c.line(slope, x1, y1, x2)
+}
+
+func (c *weierstrass) double(x, y *big.Int) (*big.Int, *big.Int) {
+	if x == nil || y.Sign() == 0 {
+		return nil, nil
+	}
+	slope := new(big.Int).Mul(x, x)
+	slope.Mul(slope, big.NewInt(3))
+	slope.Add(slope, c.A)
+	denominator := new(big.Int).Lsh(y, 1)
+	denominator.ModInverse(denominator.Mod(denominator, c.P), c.P)
+	slope.Mul(slope, denominator)
+	slope.Mod(slope, c.P)
+	return c.line(slope, x, y, x)
+}
+
+// line completes an addition or a doubling once the slope is known
+func (c *weierstrass) line(slope, x1, y1, x2 *big.Int) (*big.Int, *big.Int) {
+	x3 := new(big.Int).Mul(slope, slope)
+	x3.Sub(x3, x1)
+	x3.Sub(x3, x2)
+	x3.Mod(x3, c.P)
+	y3 := new(big.Int).Sub(x1, x3)
+	y3.Mul(y3, slope)
+	y3.Sub(y3, y1)
+	y3.Mod(y3, c.P)
+	return x3, y3
+}
+
+func (c *weierstrass) scalarMult(x, y, k *big.Int) (rx, ry *big.Int) {
+	for index := k.BitLen() - 1; index >= 0; index-- {
+		rx, ry = c.double(rx, ry)
+		if k.Bit(index) == 1 {
+			rx, ry = c.add(rx, ry, x, y)
+		}
+	}
+	return
+}
+
+// verify is the ECDSA verification of SEC 1 section 4.1.4
+func (k *weierstrassKey) verify(digest []byte, r, s *big.Int) bool {
+	c := k.Curve
+	if r.Sign() <= 0 || s.Sign() <= 0 || r.Cmp(c.N) >= 0 || s.Cmp(c.N) >= 0 {
+		return false
+	}
+	e := new(big.Int).SetBytes(digest)
+	if excess := len(digest)*8 - c.N.BitLen(); excess > 0 {
+		e.Rsh(e, uint(excess))
+	}
+	w := new(big.Int).ModInverse(s, c.N)
+	u1 := e.Mul(e, w)
+	u1.Mod(u1, c.N)
+	u2 := w.Mul(r, w)
+	u2.Mod(u2, c.N)
+	x1, y1 := c.scalarMult(c.Gx, c.Gy, u1)
+	x2, y2 := c.scalarMult(k.X, k.Y, u2)
+	x, _ := c.add(x1, y1, x2, y2)
+	if x == nil {
+		return false
+	}
+	return new(big.Int).Mod(x, c.N).Cmp(r) == 0
+}
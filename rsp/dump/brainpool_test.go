@@ -0,0 +1,108 @@
+package dump
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"math/big"
+	"testing"
+)
+
+// known answers computed with OpenSSL 3.0 on brainpoolP256r1
+const (
+	testBrainpool2G = "04743cf1b8b5cd4f2eb55f8aa369593ac436ef044166699e37d51a14c2ce13ea0e36ed163337deba9c946fe0bb776529da38df059f69249406892ada097eeb7cd4"
+	testBrainpool3G = "04a8f217b77338f1d4d6624c3ab4f6cc16d2aa843d0c0fca016b91e2ad25cae39d4b49cafc7dac26bb0aa2a6850a1b40f5fac10e4589348fb77e65cc5602b74f9d"
+	testBrainpoolD  = "63ab05b2bdeedc7965eaab4cd1ea7a3bb2e3eab5e3ff6e3f07d94b50857ff857"
+	// Q = dG and (d + 1)G
+	testBrainpoolQ      = "04385ce339b9a1a26649c73e7fe04215bb543c4e18e424182437f2db756f7dd0a09d8e1d778d8435b772a70699f85341c599030175b535cd29bc2dae672be928f5"
+	testBrainpoolQPlusG = "042ecb31e951ceefca98d256d8e822e1c09a3e74bb4e0a498be972893061d6c6670cf6e308ae944324b806a9c23a136c83aa5b6f1bc499b3db6813d104cc4cd39b"
+	// ecdsa-with-SHA256 of testBrainpoolMessage with d, openssl dgst -sha256 -sign
+	testBrainpoolMessage = "eUICC signed data"
+	testBrainpoolR       = "53b24fcd5d57c169b2c413f4a71a83081ec8604ca651b091a513b232b19d2ad9"
+	testBrainpoolS       = "2b82ee0a0d917112a653507f9e1a214205b26b6b10ff93788e36c3bf845b3997"
+)
+
+func testBrainpoolPoint(t *testing.T, encoded string) *weierstrassKey {
+	t.Helper()
+	data, _ := hex.DecodeString(encoded)
+	point, err := brainpoolP256r1.unmarshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return point
+}
+
+func assertPoint(t *testing.T, name string, x, y *big.Int, want *weierstrassKey) {
+	t.Helper()
+	if x == nil || x.Cmp(want.X) != 0 || y.Cmp(want.Y) != 0 {
+		t.Fatalf("%s = (%x, %x), want (%x, %x)", name, x, y, want.X, want.Y)
+	}
+}
+
+func TestWeierstrassArithmetic(t *testing.T) {
+	c := brainpoolP256r1
+	g := &weierstrassKey{Curve: c, X: c.Gx, Y: c.Gy}
+	if !c.isOnCurve(g.X, g.Y) {
+		t.Fatal("G is not on the curve")
+	}
+	twoG, threeG := testBrainpoolPoint(t, testBrainpool2G), testBrainpoolPoint(t, testBrainpool3G)
+	q, qPlusG := testBrainpoolPoint(t, testBrainpoolQ), testBrainpoolPoint(t, testBrainpoolQPlusG)
+
+	x, y := c.double(g.X, g.Y)
+	assertPoint(t, "double(G)", x, y, twoG)
+	x, y = c.add(g.X, g.Y, g.X, g.Y)
+	assertPoint(t, "G + G", x, y, twoG)
+	x, y = c.add(twoG.X, twoG.Y, g.X, g.Y)
+	assertPoint(t, "2G + G", x, y, threeG)
+	x, y = c.add(q.X, q.Y, g.X, g.Y)
+	assertPoint(t, "Q + G", x, y, qPlusG)
+	x, y = c.add(nil, nil, q.X, q.Y)
+	assertPoint(t, "O + Q", x, y, q)
+	if x, _ = c.add(q.X, q.Y, q.X, new(big.Int).Sub(c.P, q.Y)); x != nil {
+		t.Fatal("Q + (-Q) is not the point at infinity")
+	}
+
+	d, _ := new(big.Int).SetString(testBrainpoolD, 16)
+	x, y = c.scalarMult(g.X, g.Y, d)
+	assertPoint(t, "dG", x, y, q)
+	x, y = c.scalarMult(g.X, g.Y, big.NewInt(3))
+	assertPoint(t, "3G", x, y, threeG)
+	if x, _ = c.scalarMult(g.X, g.Y, c.N); x != nil {
+		t.Fatal("nG is not the point at infinity")
+	}
+}
+
+func TestWeierstrassVerify(t *testing.T) {
+	key := testBrainpoolPoint(t, testBrainpoolQ)
+	digest := sha256.Sum256([]byte(testBrainpoolMessage))
+	r, _ := new(big.Int).SetString(testBrainpoolR, 16)
+	s, _ := new(big.Int).SetString(testBrainpoolS, 16)
+	if !key.verify(digest[:], r, s) {
+		t.Fatal("valid signature is rejected")
+	}
+	signature, _ := hex.DecodeString(testBrainpoolR + testBrainpoolS)
+	if err := verifySignature(key, []byte(testBrainpoolMessage), signature); err != nil {
+		t.Fatalf("verifySignature: %v", err)
+	}
+
+	tampered := sha256.Sum256([]byte(testBrainpoolMessage + "!"))
+	if key.verify(tampered[:], r, s) {
+		t.Fatal("signature of another message is accepted")
+	}
+	if key.verify(digest[:], new(big.Int).Add(r, big.NewInt(1)), s) {
+		t.Fatal("tampered r is accepted")
+	}
+	if key.verify(digest[:], r, new(big.Int).Add(s, big.NewInt(1))) {
+		t.Fatal("tampered s is accepted")
+	}
+	if key.verify(digest[:], r, new(big.Int).Add(s, brainpoolP256r1.N)) {
+		t.Fatal("s out of range is accepted")
+	}
+	other := testBrainpoolPoint(t, testBrainpool2G)
+	if other.verify(digest[:], r, s) {
+		t.Fatal("signature is accepted with another key")
+	}
+	signature[len(signature)-1] ^= 0x01
+	if err := verifySignature(key, []byte(testBrainpoolMessage), signature); err == nil {
+		t.Fatal("tampered raw signature is accepted")
+	}
+}
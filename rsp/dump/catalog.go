@@ -0,0 +1,108 @@
+package dump
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"slices"
+	"sync/atomic"
+)
+
+// catalog is a JSON list bundled with the binary, entries loaded at runtime
+// are merged over it and replace the bundled entry holding the same key.
+type catalog[E any] struct {
+	name     string
+	bundled  []byte
+	key      func(E) string
+	validate func([]E) error
+	entries  atomic.Pointer[[]E]
+}
+
+func newCatalog[E any](name string, bundled []byte, key func(E) string, validate func([]E) error) *catalog[E] {
+	c := &catalog[E]{name: name, bundled: bundled, key: key, validate: validate}
+	if err := c.set(nil); err != nil {
+		panic(err)
+	}
+	return c
+}
+
+func (c *catalog[E]) read(r io.Reader) (entries []E, err error) {
+	if err = json.NewDecoder(r).Decode(&entries); err != nil {
+		return
+	}
+	if err = c.validate(entries); err != nil {
+		return nil, fmt.Errorf("%s catalog: %w", c.name, err)
+	}
+	return
+}
+
+// set validates the merged catalog as a whole before it replaces the current one
+func (c *catalog[E]) set(entries []E) error {
+	merged, err := c.read(bytes.NewReader(c.bundled))
+	if err != nil {
+		return fmt.Errorf("bundled %w", err)
+	}
+	for _, entry := range entries {
+		index := slices.IndexFunc(merged, func(known E) bool { return c.key(known) == c.key(entry) })
+		if index == -1 {
+			merged = append(merged, entry)
+		} else {
+			merged[index] = entry
+		}
+	}
+	if err = c.validate(merged); err != nil {
+		return fmt.Errorf("%s catalog: %w", c.name, err)
+	}
+	c.entries.Store(&merged)
+	return nil
+}
+
+func (c *catalog[E]) load() []E {
+	return *c.entries.Load()
+}
+
+// LoadCatalogs installs the CI, EUM and SAS catalog files over the bundled catalogs,
+// an empty name restores the bundled one. Nothing is installed unless every file is valid.
+func LoadCatalogs(ciName, eumName, sasName string) (err error) {
+	var cis CICatalog
+	var eums EUMCatalog
+	var sites SASCatalog
+	if cis, err = readCatalogFile(ciName, ReadCICatalog); err != nil {
+		return
+	}
+	if eums, err = readCatalogFile(eumName, ReadEUMCatalog); err != nil {
+		return
+	}
+	if sites, err = readCatalogFile(sasName, ReadSASCatalog); err != nil {
+		return
+	}
+	// the CI catalog is the only one whose merge can fail, installing it first keeps the others untouched
+	if err = SetCICatalog(cis); err != nil {
+		return
+	}
+	if err = SetEUMCatalog(eums); err != nil {
+		return
+	}
+	if err = SetSASCatalog(sites); err == nil && len(sasCatalog.load()) == 0 {
+		log.Println("SAS: neither sas-catalog.json nor sas_catalog_file lists a SAS-UP site, every accreditation is unknown")
+	}
+	return
+}
+
+func readCatalogFile[C any](name string, read func(io.Reader) (C, error)) (entries C, err error) {
+	if name == "" {
+		return
+	}
+	fp, err := os.Open(name)
+	if err != nil {
+		return
+	}
+	defer fp.Close()
+	if entries, err = read(fp); err != nil {
+		err = fmt.Errorf("%s: %w", name, err)
+	}
+	return
+}
@@ -0,0 +1,88 @@
+package dump
+
+import (
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSetCICatalogAliasCollision(t *testing.T) {
+	t.Cleanup(func() { _ = SetCICatalog(nil) })
+	catalog, err := ReadCICatalog(strings.NewReader(`[{"keyId": "0102030405060708090a0b0c0d0e0f1011121314", "name": "Example CI", "aliases": ["gsma-g1"]}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = SetCICatalog(catalog); err == nil {
+		t.Fatal("alias of a bundled CI is accepted for another key id")
+	}
+	if ci := LookupCIAlias("gsma-g1"); ci == nil || ci.Name != "GSMA CI G1" {
+		t.Fatalf("LookupCIAlias = %+v, want the bundled CI kept", ci)
+	}
+	catalog[0].Aliases = []string{"example"}
+	if err = SetCICatalog(catalog); err != nil {
+		t.Fatal(err)
+	}
+	if ci := LookupCIAlias("example"); ci == nil || ci.Name != "Example CI" {
+		t.Fatalf("LookupCIAlias = %+v, want Example CI", ci)
+	}
+}
+
+func TestCICatalogReservedAliases(t *testing.T) {
+	t.Cleanup(func() { _ = SetCICatalog(nil) })
+	for _, alias := range []string{"81370f", "abc", "production", "test"} {
+		t.Run(alias, func(t *testing.T) {
+			document := `[{"keyId": "0102030405060708090a0b0c0d0e0f1011121314", "name": "Example CI", "aliases": ["` + alias + `"]}]`
+			if _, err := ReadCICatalog(strings.NewReader(document)); err == nil {
+				t.Fatalf("ReadCICatalog accepted the alias %q", alias)
+			}
+			keyId, _ := hex.DecodeString("0102030405060708090a0b0c0d0e0f1011121314")
+			catalog := CICatalog{{KeyId: keyId, Name: "Example CI", Aliases: []string{alias}}}
+			if err := SetCICatalog(catalog); err == nil {
+				t.Fatalf("SetCICatalog accepted the alias %q", alias)
+			}
+			if LookupCIAlias(alias) != nil {
+				t.Fatalf("LookupCIAlias(%q) found the rejected CI", alias)
+			}
+		})
+	}
+}
+
+func TestSetEUMCatalogOverride(t *testing.T) {
+	t.Cleanup(func() { _ = SetEUMCatalog(nil) })
+	if err := SetEUMCatalog(EUMCatalog{{Prefix: "89049032", Name: "Renamed"}, {Prefix: "8904903200", Name: "Longer"}}); err != nil {
+		t.Fatal(err)
+	}
+	if name := LookupEUM("89049032000000000000000000000000"); name != "Longer" {
+		t.Fatalf("LookupEUM = %q, want the longest prefix", name)
+	}
+	if name := LookupEUM("89049032100000000000000000000000"); name != "Renamed" {
+		t.Fatalf("LookupEUM = %q, want the replaced bundled entry", name)
+	}
+	_ = SetEUMCatalog(nil)
+	if name := LookupEUM("89049032100000000000000000000000"); name != "Giesecke+Devrient" {
+		t.Fatalf("LookupEUM = %q, want the bundled entry restored", name)
+	}
+}
+
+func TestLoadCatalogs(t *testing.T) {
+	t.Cleanup(func() { _ = LoadCatalogs("", "", "") })
+	directory := t.TempDir()
+	eums := filepath.Join(directory, "eum.json")
+	invalid := filepath.Join(directory, "sas.json")
+	_ = os.WriteFile(eums, []byte(`[{"prefix": "89001001", "name": "Example EUM"}]`), 0644)
+	_ = os.WriteFile(invalid, []byte(`[{"accreditationNumber": "XX-YY-UP-0001", "validUntil": "soon"}]`), 0644)
+	if err := LoadCatalogs("", eums, invalid); err == nil {
+		t.Fatal("invalid SAS catalog is accepted")
+	}
+	if name := LookupEUM("89001001000000000000000000000000"); name != "" {
+		t.Fatalf("LookupEUM = %q, want nothing installed after a failed load", name)
+	}
+	if err := LoadCatalogs("", eums, ""); err != nil {
+		t.Fatal(err)
+	}
+	if name := LookupEUM("89001001000000000000000000000000"); name != "Example EUM" {
+		t.Fatalf("LookupEUM = %q, want Example EUM", name)
+	}
+}
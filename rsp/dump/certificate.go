@@ -0,0 +1,716 @@
+package dump
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"strings"
+	"time"
+)
+
+// certificate is the X.509 structure of RFC 5280, parsed without the key and curve
+// restrictions of crypto/x509 so that every certificate of the eUICC chain can be rendered.
+type certificate struct {
+	TBSCertificate     tbsCertificate
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	SignatureValue     asn1.BitString
+}
+
+type tbsCertificate struct {
+	Raw                asn1.RawContent
+	Version            int `asn1:"optional,explicit,default:0,tag:0"`
+	SerialNumber       *big.Int
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Issuer             asn1.RawValue
+	Validity           struct{ NotBefore, NotAfter time.Time }
+	Subject            asn1.RawValue
+	PublicKey          struct {
+		Raw       asn1.RawContent
+		Algorithm pkix.AlgorithmIdentifier
+		PublicKey asn1.BitString
+	}
+	IssuerUniqueId  asn1.BitString   `asn1:"optional,tag:1"`
+	SubjectUniqueId asn1.BitString   `asn1:"optional,tag:2"`
+	Extensions      []pkix.Extension `asn1:"optional,explicit,tag:3"`
+}
+
+func parseRawCertificate(data []byte) (*certificate, error) {
+	cert := new(certificate)
+	if rest, err := asn1.Unmarshal(data, cert); err != nil {
+		return nil, err
+	} else if len(rest) > 0 {
+		return nil, fmt.Errorf("trailing data after certificate: %d bytes", len(rest))
+	}
+	return cert, nil
+}
+
+func (c *certificate) extension(id string) []byte {
+	for _, extension := range c.TBSCertificate.Extensions {
+		if extension.Id.String() == id {
+			return extension.Value
+		}
+	}
+	return nil
+}
+
+func (c *certificate) subjectKeyId() (keyId []byte) {
+	if value := c.extension("2.5.29.14"); value != nil {
+		_, _ = asn1.Unmarshal(value, &keyId)
+	}
+	return
+}
+
+func (c *certificate) authorityKeyId() []byte {
+	var aki struct {
+		KeyId []byte `asn1:"optional,tag:0"`
+	}
+	if value := c.extension("2.5.29.35"); value != nil {
+		_, _ = asn1.Unmarshal(value, &aki)
+	}
+	return aki.KeyId
+}
+
+func (c *certificate) subject() (rdn pkix.RDNSequence, err error) {
+	_, err = asn1.Unmarshal(c.TBSCertificate.Subject.FullBytes, &rdn)
+	return
+}
+
+// permittedNames are the directoryName subtrees permitted by the name constraints extension
+func (c *certificate) permittedNames() (names []pkix.RDNSequence, err error) {
+	value := c.extension("2.5.29.30")
+	if value == nil {
+		return
+	}
+	var constraints struct {
+		Permitted asn1.RawValue `asn1:"optional,tag:0"`
+		Excluded  asn1.RawValue `asn1:"optional,tag:1"`
+	}
+	if _, err = asn1.Unmarshal(value, &constraints); err != nil {
+		return
+	}
+	for rest := constraints.Permitted.Bytes; len(rest) > 0; {
+		var subtree struct {
+			Base asn1.RawValue
+			Rest asn1.RawContent `asn1:"optional"`
+		}
+		if rest, err = asn1.Unmarshal(rest, &subtree); err != nil {
+			return
+		}
+		if subtree.Base.Class != asn1.ClassContextSpecific || subtree.Base.Tag != 4 {
+			continue
+		}
+		var name pkix.RDNSequence
+		if _, err = asn1.Unmarshal(subtree.Base.Bytes, &name); err != nil {
+			return
+		}
+		names = append(names, name)
+	}
+	return
+}
+
+// publicKey is an *ecdsa.PublicKey, a *weierstrassKey for the Brainpool curves
+// or any other key type of x509.ParsePKIXPublicKey
+func (c *certificate) publicKey() (any, error) {
+	info := &c.TBSCertificate.PublicKey
+	if info.Algorithm.Algorithm.String() == "1.2.840.10045.2.1" {
+		var curveId asn1.ObjectIdentifier
+		if _, err := asn1.Unmarshal(info.Algorithm.Parameters.FullBytes, &curveId); err == nil {
+			if curve, ok := weierstrassCurves[curveId.String()]; ok {
+				return curve.unmarshal(info.PublicKey.RightAlign())
+			}
+		}
+	}
+	return x509.ParsePKIXPublicKey(info.Raw)
+}
+
+// checkSignatureFrom verifies the ECDSA signature of the certificate with the issuer key
+func (c *certificate) checkSignatureFrom(publicKey any) error {
+	var digest []byte
+	switch algorithm := c.SignatureAlgorithm.Algorithm.String(); algorithm {
+	case "1.2.840.10045.4.3.2":
+		sum := sha256.Sum256(c.TBSCertificate.Raw)
+		digest = sum[:]
+	case "1.2.840.10045.4.3.3":
+		sum := sha512.Sum384(c.TBSCertificate.Raw)
+		digest = sum[:]
+	case "1.2.840.10045.4.3.4":
+		sum := sha512.Sum512(c.TBSCertificate.Raw)
+		digest = sum[:]
+	default:
+		return fmt.Errorf("unsupported signature algorithm %s", oidName(c.SignatureAlgorithm.Algorithm))
+	}
+	var signature struct{ R, S *big.Int }
+	if rest, err := asn1.Unmarshal(c.SignatureValue.RightAlign(), &signature); err != nil || len(rest) > 0 {
+		return errors.New("malformed signature")
+	}
+	return verifyECDSA(publicKey, digest, signature.R, signature.S)
+}
+
+func (c *certificate) checkValidity(now time.Time) error {
+	validity := c.TBSCertificate.Validity
+	switch {
+	case now.Before(validity.NotBefore):
+		return fmt.Errorf("not valid before %s", formatTime(validity.NotBefore))
+	case now.After(validity.NotAfter):
+		return fmt.Errorf("expired at %s", formatTime(validity.NotAfter))
+	}
+	return nil
+}
+
+var (
+	oidNames = map[string]string{
+		"1.2.840.10045.2.1":          "id-ecPublicKey",
+		"1.2.840.113549.1.1.1":       "rsaEncryption",
+		"1.2.840.10045.4.3.2":        "ecdsa-with-SHA256",
+		"1.2.840.10045.4.3.3":        "ecdsa-with-SHA384",
+		"1.2.840.10045.4.3.4":        "ecdsa-with-SHA512",
+		"1.2.840.113549.1.1.11":      "sha256WithRSAEncryption",
+		"1.2.840.113549.1.1.12":      "sha384WithRSAEncryption",
+		"1.2.840.113549.1.1.13":      "sha512WithRSAEncryption",
+		"1.3.101.112":                "ED25519",
+		"1.3.6.1.5.5.7.3.1":          "TLS Web Server Authentication",
+		"1.3.6.1.5.5.7.3.2":          "TLS Web Client Authentication",
+		"2.23.146.1.2.1.0":           "id-rspRole-ci",
+		"2.23.146.1.2.1.1":           "id-rspRole-euicc",
+		"2.23.146.1.2.1.2":           "id-rspRole-eum",
+		"2.23.146.1.2.1.3":           "id-rspRole-dp-tls",
+		"2.23.146.1.2.1.4":           "id-rspRole-dp-auth",
+		"2.23.146.1.2.1.5":           "id-rspRole-dp-pb",
+		"2.23.146.1.2.1.6":           "id-rspRole-ds-tls",
+		"2.23.146.1.2.1.7":           "id-rspRole-ds-auth",
+		"2.5.29.32.0":                "X509v3 Any Policy",
+		"0.9.2342.19200300.100.1.25": "domainComponent",
+	}
+	attributeNames = map[string]string{
+		"2.5.4.3":              "commonName",
+		"2.5.4.4":              "surname",
+		"2.5.4.5":              "serialNumber",
+		"2.5.4.6":              "countryName",
+		"2.5.4.7":              "localityName",
+		"2.5.4.8":              "stateOrProvinceName",
+		"2.5.4.9":              "streetAddress",
+		"2.5.4.10":             "organizationName",
+		"2.5.4.11":             "organizationalUnitName",
+		"2.5.4.12":             "title",
+		"2.5.4.42":             "givenName",
+		"2.5.4.97":             "organizationIdentifier",
+		"1.2.840.113549.1.9.1": "emailAddress",
+	}
+	attributeShortNames = map[string]string{
+		"2.5.4.3":                    "CN",
+		"2.5.4.4":                    "SN",
+		"2.5.4.6":                    "C",
+		"2.5.4.7":                    "L",
+		"2.5.4.8":                    "ST",
+		"2.5.4.9":                    "street",
+		"2.5.4.10":                   "O",
+		"2.5.4.11":                   "OU",
+		"2.5.4.42":                   "GN",
+		"0.9.2342.19200300.100.1.25": "DC",
+	}
+	extensionNames = map[string]string{
+		"2.5.29.14":        "X509v3 Subject Key Identifier",
+		"2.5.29.15":        "X509v3 Key Usage",
+		"2.5.29.17":        "X509v3 Subject Alternative Name",
+		"2.5.29.18":        "X509v3 Issuer Alternative Name",
+		"2.5.29.19":        "X509v3 Basic Constraints",
+		"2.5.29.30":        "X509v3 Name Constraints",
+		"2.5.29.31":        "X509v3 CRL Distribution Points",
+		"2.5.29.32":        "X509v3 Certificate Policies",
+		"2.5.29.35":        "X509v3 Authority Key Identifier",
+		"2.5.29.37":        "X509v3 Extended Key Usage",
+		"2.23.146.1.2.0.1": "GSMA RSP Expiration Date",
+	}
+	keyUsages = []string{
+		"Digital Signature", "Non Repudiation", "Key Encipherment", "Data Encipherment",
+		"Key Agreement", "Certificate Sign", "CRL Sign", "Encipher Only", "Decipher Only",
+	}
+	namedCurves = map[string]struct {
+		name, nist string
+		bits       int
+	}{
+		"1.2.840.10045.3.1.7":   {"prime256v1", "P-256", 256},
+		"1.3.132.0.34":          {"secp384r1", "P-384", 384},
+		"1.3.132.0.35":          {"secp521r1", "P-521", 521},
+		"1.3.36.3.3.2.8.1.1.7":  {"brainpoolP256r1", "", 256},
+		"1.3.36.3.3.2.8.1.1.11": {"brainpoolP384r1", "", 384},
+	}
+)
+
+func oidName(oid asn1.ObjectIdentifier) string {
+	if name, ok := oidNames[oid.String()]; ok {
+		return name
+	}
+	return oid.String()
+}
+
+// renderCertificate prints the certificate like `openssl x509 -text -certopt ext_parse
+// -nameopt sep_multiline,space_eq,lname,utf8` followed by the PEM block.
+func renderCertificate(data []byte) ([]byte, error) {
+	cert, err := parseRawCertificate(data)
+	if err != nil {
+		return nil, err
+	}
+	w := &textWriter{}
+	tbs := &cert.TBSCertificate
+	w.line(0, "Certificate:")
+	w.line(4, "Data:")
+	w.line(8, "Version: %d (0x%x)", tbs.Version+1, tbs.Version)
+	if serial := tbs.SerialNumber; serial != nil && len(serial.Bytes()) <= 8 {
+		w.line(8, "Serial Number: %s (%s0x%x)", serial, sign(serial), new(big.Int).Abs(serial))
+	} else if serial != nil {
+		w.line(8, "Serial Number:")
+		w.line(12, "%s%s", sign(serial), colonHex(serial.Bytes(), false))
+	}
+	w.line(8, "Signature Algorithm: %s", oidName(tbs.SignatureAlgorithm.Algorithm))
+	w.line(8, "Issuer:")
+	w.name(12, tbs.Issuer.FullBytes)
+	w.line(8, "Validity")
+	w.line(12, "Not Before: %s", formatTime(tbs.Validity.NotBefore))
+	w.line(12, "Not After : %s", formatTime(tbs.Validity.NotAfter))
+	w.line(8, "Subject:")
+	w.name(12, tbs.Subject.FullBytes)
+	w.line(8, "Subject Public Key Info:")
+	w.publicKey(12, tbs)
+	if len(tbs.Extensions) > 0 {
+		w.line(8, "X509v3 extensions:")
+		for _, extension := range tbs.Extensions {
+			w.extension(12, extension)
+		}
+	}
+	w.line(4, "Signature Algorithm: %s", oidName(cert.SignatureAlgorithm.Algorithm))
+	w.line(4, "Signature Value:")
+	w.hexBlock(8, cert.SignatureValue.Bytes, 18)
+	_ = pem.Encode(&w.Buffer, &pem.Block{Type: "CERTIFICATE", Bytes: data})
+	return w.Bytes(), nil
+}
+
+type textWriter struct {
+	bytes.Buffer
+}
+
+func (w *textWriter) line(indent int, format string, args ...any) {
+	w.WriteString(strings.Repeat(" ", indent))
+	_, _ = fmt.Fprintf(w, format, args...)
+	w.WriteByte('\n')
+}
+
+func (w *textWriter) hexBlock(indent int, data []byte, width int) {
+	for offset := 0; offset < len(data); offset += width {
+		end := min(offset+width, len(data))
+		chunk := colonHex(data[offset:end], false)
+		if end < len(data) {
+			chunk += ":"
+		}
+		w.line(indent, "%s", chunk)
+	}
+}
+
+func (w *textWriter) name(indent int, raw []byte) {
+	var rdn pkix.RDNSequence
+	if _, err := asn1.Unmarshal(raw, &rdn); err != nil {
+		w.line(indent, "<unparsable name %s>", hex.EncodeToString(raw))
+		return
+	}
+	for _, set := range rdn {
+		for _, attribute := range set {
+			w.line(indent, "%s", formatAttribute(attribute))
+		}
+	}
+}
+
+func formatAttribute(attribute pkix.AttributeTypeAndValue) string {
+	name, ok := attributeNames[attribute.Type.String()]
+	if !ok {
+		name = oidName(attribute.Type)
+	}
+	return fmt.Sprintf("%s = %v", name, attribute.Value)
+}
+
+// formatShortAttribute is formatAttribute with the short names used inside general names
+func formatShortAttribute(attribute pkix.AttributeTypeAndValue) string {
+	if name, ok := attributeShortNames[attribute.Type.String()]; ok {
+		return fmt.Sprintf("%s = %v", name, attribute.Value)
+	}
+	return formatAttribute(attribute)
+}
+
+func (w *textWriter) publicKey(indent int, tbs *tbsCertificate) {
+	algorithm := tbs.PublicKey.Algorithm
+	key := tbs.PublicKey.PublicKey.Bytes
+	w.line(indent, "Public Key Algorithm: %s", oidName(algorithm.Algorithm))
+	switch algorithm.Algorithm.String() {
+	case "1.2.840.10045.2.1":
+		var curveId asn1.ObjectIdentifier
+		if _, err := asn1.Unmarshal(algorithm.Parameters.FullBytes, &curveId); err != nil {
+			w.line(indent+4, "Unable to decode the curve parameters")
+			w.line(indent+4, "pub:")
+			w.hexBlock(indent+8, key, 15)
+			return
+		}
+		curve, known := namedCurves[curveId.String()]
+		if known {
+			w.line(indent+4, "Public-Key: (%d bit)", curve.bits)
+		}
+		w.line(indent+4, "pub:")
+		w.hexBlock(indent+8, key, 15)
+		if !known {
+			w.line(indent+4, "ASN1 OID: %s", curveId)
+			return
+		}
+		w.line(indent+4, "ASN1 OID: %s", curve.name)
+		if curve.nist != "" {
+			w.line(indent+4, "NIST CURVE: %s", curve.nist)
+		}
+	case "1.2.840.113549.1.1.1":
+		var rsaKey struct {
+			N *big.Int
+			E int
+		}
+		if _, err := asn1.Unmarshal(key, &rsaKey); err != nil || rsaKey.N == nil {
+			w.line(indent+4, "Unable to decode the RSA key")
+			return
+		}
+		modulus := rsaKey.N.Bytes()
+		if len(modulus) > 0 && modulus[0]&0x80 != 0 {
+			modulus = append([]byte{0}, modulus...)
+		}
+		w.line(indent+4, "Public-Key: (%d bit)", rsaKey.N.BitLen())
+		w.line(indent+4, "Modulus:")
+		w.hexBlock(indent+8, modulus, 15)
+		w.line(indent+4, "Exponent: %d (0x%x)", rsaKey.E, rsaKey.E)
+	default:
+		w.line(indent+4, "pub:")
+		w.hexBlock(indent+8, key, 15)
+	}
+}
+
+func (w *textWriter) extension(indent int, extension pkix.Extension) {
+	name, ok := extensionNames[extension.Id.String()]
+	if !ok {
+		name = oidName(extension.Id)
+	}
+	if extension.Critical {
+		w.line(indent, "%s: critical", name)
+	} else {
+		w.line(indent, "%s: ", name)
+	}
+	indent += 4
+	if err := w.extensionValue(indent, extension); err != nil {
+		w.dump(extension.Value)
+	}
+}
+
+func (w *textWriter) extensionValue(indent int, extension pkix.Extension) (err error) {
+	value := extension.Value
+	switch extension.Id.String() {
+	case "2.5.29.14":
+		var keyId []byte
+		if _, err = asn1.Unmarshal(value, &keyId); err == nil {
+			w.line(indent, "%s", colonHex(keyId, true))
+		}
+	case "2.5.29.35":
+		var aki struct {
+			KeyId        []byte        `asn1:"optional,tag:0"`
+			Issuer       asn1.RawValue `asn1:"optional,tag:1"`
+			SerialNumber *big.Int      `asn1:"optional,tag:2"`
+		}
+		if _, err = asn1.Unmarshal(value, &aki); err != nil {
+			return
+		}
+		if aki.KeyId != nil {
+			w.line(indent, "%s", colonHex(aki.KeyId, true))
+		}
+		if len(aki.Issuer.Bytes) > 0 {
+			err = w.generalNames(indent, aki.Issuer.Bytes)
+		}
+		if aki.SerialNumber != nil {
+			w.line(indent, "serial:%s", colonHex(aki.SerialNumber.Bytes(), true))
+		}
+	case "2.5.29.15":
+		var usage asn1.BitString
+		if _, err = asn1.Unmarshal(value, &usage); err != nil {
+			return
+		}
+		var names []string
+		for index, name := range keyUsages {
+			if usage.At(index) != 0 {
+				names = append(names, name)
+			}
+		}
+		w.line(indent, "%s", strings.Join(names, ", "))
+	case "2.5.29.19":
+		var constraints struct {
+			CA      bool `asn1:"optional"`
+			PathLen int  `asn1:"optional,default:-1"`
+		}
+		if _, err = asn1.Unmarshal(value, &constraints); err != nil {
+			return
+		}
+		text := "CA:FALSE"
+		if constraints.CA {
+			text = "CA:TRUE"
+		}
+		if constraints.PathLen >= 0 {
+			text += fmt.Sprintf(", pathlen:%d", constraints.PathLen)
+		}
+		w.line(indent, "%s", text)
+	case "2.5.29.17", "2.5.29.18":
+		var sequence asn1.RawValue
+		if _, err = asn1.Unmarshal(value, &sequence); err != nil {
+			return
+		}
+		var names []string
+		if names, err = generalNames(sequence.Bytes); err == nil {
+			w.line(indent, "%s", strings.Join(names, ", "))
+		}
+	case "2.5.29.32":
+		var policies []struct {
+			Policy     asn1.ObjectIdentifier
+			Qualifiers asn1.RawValue `asn1:"optional"`
+		}
+		if _, err = asn1.Unmarshal(value, &policies); err != nil {
+			return
+		}
+		for _, policy := range policies {
+			// openssl names the policies it knows (anyPolicy), the GSMA RSP roles stay numeric
+			if oid := policy.Policy.String(); strings.HasPrefix(oid, "2.23.146.") {
+				w.line(indent, "Policy: %s", oid)
+			} else {
+				w.line(indent, "Policy: %s", oidName(policy.Policy))
+			}
+			if len(policy.Qualifiers.FullBytes) > 0 {
+				w.dump(policy.Qualifiers.FullBytes)
+			}
+		}
+	case "2.5.29.37":
+		var usages []asn1.ObjectIdentifier
+		if _, err = asn1.Unmarshal(value, &usages); err != nil {
+			return
+		}
+		names := make([]string, len(usages))
+		for index, usage := range usages {
+			names[index] = oidName(usage)
+		}
+		w.line(indent, "%s", strings.Join(names, ", "))
+	case "2.5.29.30":
+		var constraints struct {
+			Permitted asn1.RawValue `asn1:"optional,tag:0"`
+			Excluded  asn1.RawValue `asn1:"optional,tag:1"`
+		}
+		if _, err = asn1.Unmarshal(value, &constraints); err != nil {
+			return
+		}
+		for _, subtrees := range []struct {
+			label string
+			raw   asn1.RawValue
+		}{{"Permitted", constraints.Permitted}, {"Excluded", constraints.Excluded}} {
+			if len(subtrees.raw.FullBytes) == 0 {
+				continue
+			}
+			w.line(indent, "%s:", subtrees.label)
+			for rest := subtrees.raw.Bytes; len(rest) > 0 && err == nil; {
+				var subtree struct {
+					Base asn1.RawValue
+					Rest asn1.RawContent `asn1:"optional"`
+				}
+				if rest, err = asn1.Unmarshal(rest, &subtree); err == nil {
+					err = w.generalNames(indent+2, subtree.Base.FullBytes)
+				}
+			}
+		}
+	case "2.5.29.31":
+		var points []asn1.RawValue
+		if _, err = asn1.Unmarshal(value, &points); err != nil {
+			return
+		}
+		for _, point := range points {
+			var name asn1.RawValue
+			if _, err = asn1.Unmarshal(point.Bytes, &name); err != nil {
+				return
+			}
+			var fullName asn1.RawValue
+			if _, err = asn1.Unmarshal(name.Bytes, &fullName); err != nil {
+				return
+			}
+			if fullName.Tag != 0 {
+				w.dump(point.FullBytes)
+				continue
+			}
+			w.line(indent, "Full Name:")
+			if err = w.generalNames(indent+2, fullName.Bytes); err != nil {
+				return
+			}
+		}
+	case "2.23.146.1.2.0.1":
+		var expiration time.Time
+		if _, err = asn1.Unmarshal(value, &expiration); err == nil {
+			w.line(indent, "%s", formatTime(expiration))
+		}
+	default:
+		w.dump(value)
+	}
+	return
+}
+
+// generalNames formats the GeneralName CHOICE elements of RFC 5280 in the openssl notation
+func generalNames(data []byte) (names []string, err error) {
+	for len(data) > 0 {
+		var name asn1.RawValue
+		if data, err = asn1.Unmarshal(data, &name); err != nil {
+			return
+		}
+		if name.Class != asn1.ClassContextSpecific {
+			return nil, fmt.Errorf("unexpected general name class %d", name.Class)
+		}
+		switch name.Tag {
+		case 1:
+			names = append(names, "email:"+string(name.Bytes))
+		case 2:
+			names = append(names, "DNS:"+string(name.Bytes))
+		case 4:
+			var rdn pkix.RDNSequence
+			if _, err = asn1.Unmarshal(name.Bytes, &rdn); err != nil {
+				return
+			}
+			var attributes []string
+			for _, set := range rdn {
+				for _, attribute := range set {
+					attributes = append(attributes, formatShortAttribute(attribute))
+				}
+			}
+			names = append(names, "DirName:"+strings.Join(attributes, ", "))
+		case 6:
+			names = append(names, "URI:"+string(name.Bytes))
+		case 7:
+			names = append(names, "IP Address:"+net.IP(name.Bytes).String())
+		case 8:
+			var oid asn1.ObjectIdentifier
+			encoded, _ := asn1.Marshal(asn1.RawValue{Tag: asn1.TagOID, Bytes: name.Bytes})
+			if _, err = asn1.Unmarshal(encoded, &oid); err != nil {
+				return
+			}
+			names = append(names, "Registered ID:"+oidName(oid))
+		default:
+			names = append(names, fmt.Sprintf("othername:<unsupported %d>", name.Tag))
+		}
+	}
+	return
+}
+
+func (w *textWriter) generalNames(indent int, data []byte) error {
+	names, err := generalNames(data)
+	for _, name := range names {
+		w.line(indent, "%s", name)
+	}
+	return err
+}
+
+var universalNames = map[int]string{
+	asn1.TagBoolean: "BOOLEAN", asn1.TagInteger: "INTEGER", asn1.TagBitString: "BIT STRING",
+	asn1.TagOctetString: "OCTET STRING", asn1.TagNull: "NULL", asn1.TagOID: "OBJECT",
+	asn1.TagEnum: "ENUMERATED", asn1.TagUTF8String: "UTF8STRING", asn1.TagSequence: "SEQUENCE",
+	asn1.TagSet: "SET", asn1.TagPrintableString: "PRINTABLESTRING", asn1.TagIA5String: "IA5STRING",
+	asn1.TagUTCTime: "UTCTIME", asn1.TagGeneralizedTime: "GENERALIZEDTIME",
+}
+
+// dump prints an ASN.1 structure like `openssl asn1parse`, the fallback of unknown extensions
+func (w *textWriter) dump(data []byte) {
+	if err := w.dumpElements(data, 0, 0); err != nil {
+		w.line(0, "Error in encoding")
+		w.line(4, "[HEX DUMP]:%s", strings.ToUpper(hex.EncodeToString(data)))
+	}
+	w.WriteByte('\n')
+}
+
+func (w *textWriter) dumpElements(data []byte, offset, depth int) error {
+	for len(data) > 0 {
+		var element asn1.RawValue
+		rest, err := asn1.Unmarshal(data, &element)
+		if err != nil {
+			return err
+		}
+		header := len(element.FullBytes) - len(element.Bytes)
+		form := "prim"
+		if element.IsCompound {
+			form = "cons"
+		}
+		name, value := dumpValue(&element)
+		text := fmt.Sprintf("%5d:d=%-2d hl=%d l=%4d %s: %s%-18s", offset, depth, header, len(element.Bytes), form, strings.Repeat(" ", depth), name)
+		if value != "" {
+			text += ":" + value
+		}
+		w.line(0, "%s", text)
+		if element.IsCompound {
+			if err = w.dumpElements(element.Bytes, offset+header, depth+1); err != nil {
+				return err
+			}
+		}
+		offset += len(element.FullBytes)
+		data = rest
+	}
+	return nil
+}
+
+func dumpValue(element *asn1.RawValue) (name, value string) {
+	switch element.Class {
+	case asn1.ClassContextSpecific:
+		return fmt.Sprintf("cont [ %d ]", element.Tag), ""
+	case asn1.ClassApplication:
+		return fmt.Sprintf("appl [ %d ]", element.Tag), ""
+	case asn1.ClassPrivate:
+		return fmt.Sprintf("priv [ %d ]", element.Tag), ""
+	}
+	name, ok := universalNames[element.Tag]
+	if !ok {
+		name = fmt.Sprintf("[UNIVERSAL %d]", element.Tag)
+	}
+	if element.IsCompound {
+		return
+	}
+	switch element.Tag {
+	case asn1.TagOID:
+		var oid asn1.ObjectIdentifier
+		if _, err := asn1.Unmarshal(element.FullBytes, &oid); err == nil {
+			value = oidName(oid)
+		}
+	case asn1.TagUTF8String, asn1.TagPrintableString, asn1.TagIA5String, asn1.TagUTCTime, asn1.TagGeneralizedTime:
+		value = string(element.Bytes)
+	case asn1.TagBoolean, asn1.TagInteger, asn1.TagEnum:
+		value = strings.ToUpper(hex.EncodeToString(element.Bytes))
+	case asn1.TagOctetString:
+		value = "[HEX DUMP]:" + strings.ToUpper(hex.EncodeToString(element.Bytes))
+	}
+	return
+}
+
+func colonHex(data []byte, upper bool) string {
+	encoded := make([]string, len(data))
+	for index, octet := range data {
+		encoded[index] = fmt.Sprintf("%02x", octet)
+		if upper {
+			encoded[index] = strings.ToUpper(encoded[index])
+		}
+	}
+	return strings.Join(encoded, ":")
+}
+
+func sign(value *big.Int) string {
+	if value.Sign() < 0 {
+		return "-"
+	}
+	return ""
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format("Jan _2 15:04:05 2006 GMT")
+}
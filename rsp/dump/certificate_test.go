@@ -0,0 +1,40 @@
+package dump
+
+import (
+	"bytes"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// golden files of `openssl x509 -text -certopt ext_parse -nameopt sep_multiline,space_eq,lname,utf8`
+// with OpenSSL 3.0, the certificate is the PEM block at their end
+func TestRenderCertificateGolden(t *testing.T) {
+	for _, name := range []string{"dp-tls-p256.txt", "eum-brainpool.txt"} {
+		t.Run(strings.TrimSuffix(name, ".txt"), func(t *testing.T) {
+			golden, err := os.ReadFile(filepath.Join("testdata", name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			block, _ := pem.Decode(golden)
+			if block == nil {
+				t.Fatal("no certificate in the golden file")
+			}
+			rendered, err := renderCertificate(block.Bytes)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(rendered, golden) {
+				got, want := strings.Split(string(rendered), "\n"), strings.Split(string(golden), "\n")
+				for index := range min(len(got), len(want)) {
+					if got[index] != want[index] {
+						t.Fatalf("line %d = %q, want %q", index+1, got[index], want[index])
+					}
+				}
+				t.Fatalf("rendered %d lines, want %d", len(got), len(want))
+			}
+		})
+	}
+}
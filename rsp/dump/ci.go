@@ -0,0 +1,134 @@
+package dump
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+//go:embed ci-catalog.json
+var defaultCICatalog []byte
+
+var aliasPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// hexAliasPattern matches the aliases that would shadow a key id prefix in hostnames
+var hexAliasPattern = regexp.MustCompile(`^[a-f0-9]+$`)
+
+var ciCatalog = newCatalog("ci", defaultCICatalog, func(ci CI) string { return ci.KeyId.String() }, validateCICatalog)
+
+// CIBundle holds the CI certificates by subject key id, Brainpool CIs included
+type CIBundle map[string]*certificate
+
+func LoadCIBundle(name string) (CIBundle, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	bundle := make(CIBundle)
+	for block, rest := pem.Decode(data); block != nil; block, rest = pem.Decode(rest) {
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		ci, err := parseRawCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		bundle[hex.EncodeToString(ci.subjectKeyId())] = ci
+	}
+	return bundle, nil
+}
+
+func (b CIBundle) lookup(keyId []byte) *certificate {
+	return b[hex.EncodeToString(keyId)]
+}
+
+type CI struct {
+	KeyId   HexString `json:"keyId"`
+	Name    string    `json:"name"`
+	Aliases []string  `json:"aliases,omitempty"`
+	Test    bool      `json:"test,omitempty"`
+}
+
+// CICatalog names the CIs by their subject key id,
+// the aliases can be used in place of the key id in hostnames.
+type CICatalog []CI
+
+func ReadCICatalog(r io.Reader) (CICatalog, error) {
+	return ciCatalog.read(r)
+}
+
+// SetCICatalog adds the CIs of ci_catalog_file to the bundled ones, a CI with a bundled key id replaces it.
+// An alias claimed by two CIs of the result is an error and keeps the current catalog.
+func SetCICatalog(catalog CICatalog) error {
+	return ciCatalog.set(catalog)
+}
+
+func validateCICatalog(catalog []CI) error {
+	aliases := make(map[string]string)
+	for _, ci := range catalog {
+		if err := validateKeyId(ci.KeyId.String()); err != nil {
+			return err
+		}
+		for _, alias := range ci.Aliases {
+			switch {
+			case !aliasPattern.MatchString(alias):
+				return fmt.Errorf("invalid alias %q", alias)
+			case hexAliasPattern.MatchString(alias):
+				return fmt.Errorf("alias %q is hex only and would shadow a key id prefix", alias)
+			case alias == IssuerClassProduction || alias == IssuerClassTest:
+				return fmt.Errorf("alias %q is reserved for the issuer class", alias)
+			}
+			if keyId, ok := aliases[alias]; ok && keyId != ci.KeyId.String() {
+				return fmt.Errorf("alias %q is used by %s and %s", alias, keyId, ci.KeyId.String())
+			}
+			aliases[alias] = ci.KeyId.String()
+		}
+	}
+	return nil
+}
+
+func LookupCI(keyId []byte) *CI {
+	catalog := ciCatalog.load()
+	for index := range catalog {
+		if bytes.Equal(catalog[index].KeyId, keyId) {
+			return &catalog[index]
+		}
+	}
+	return nil
+}
+
+func LookupCIAlias(alias string) *CI {
+	catalog := ciCatalog.load()
+	for index := range catalog {
+		if slices.Contains(catalog[index].Aliases, strings.ToLower(alias)) {
+			return &catalog[index]
+		}
+	}
+	return nil
+}
+
+// CIName returns the catalog name of the key id, or an empty string if it is unknown
+func CIName(keyId []byte) string {
+	if ci := LookupCI(keyId); ci != nil {
+		return ci.Name
+	}
+	return ""
+}
+
+// CILabel returns the first alias of the key id, or its first 3 bytes in hex
+func CILabel(keyId []byte) string {
+	if ci := LookupCI(keyId); ci != nil && len(ci.Aliases) > 0 {
+		return ci.Aliases[0]
+	}
+	if len(keyId) > 3 {
+		keyId = keyId[:3]
+	}
+	return hex.EncodeToString(keyId)
+}
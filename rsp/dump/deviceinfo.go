@@ -0,0 +1,168 @@
+package dump
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	. "github.com/euicc-go/bertlv"
+	"strings"
+)
+
+// DeviceInfo ::= SEQUENCE, sent by the LPA in ctxParams1 of AuthenticateServer.
+// Elements with an unknown tag, duplicated or invalid are kept in Raw.
+type DeviceInfo struct {
+	TAC          string             `json:"tac"`
+	IMEI         string             `json:"imei,omitempty"`
+	Capabilities DeviceCapabilities `json:"deviceCapabilities"`
+	Raw          []*TLV             `json:"rawElements,omitempty"`
+}
+
+type DeviceCapabilities struct {
+	GSMRelease           *Version `json:"gsmSupportedRelease,omitempty"`
+	UTRANRelease         *Version `json:"utranSupportedRelease,omitempty"`
+	CDMA2000OneXRelease  *Version `json:"cdma2000onexSupportedRelease,omitempty"`
+	CDMA2000HRPDRelease  *Version `json:"cdma2000hrpdSupportedRelease,omitempty"`
+	CDMA2000EHRPDRelease *Version `json:"cdma2000ehrpdSupportedRelease,omitempty"`
+	EUTRANEPCRelease     *Version `json:"eutranEpcSupportedRelease,omitempty"`
+	ContactlessRelease   *Version `json:"contactlessSupportedRelease,omitempty"`
+	RSPCRLVersion        *Version `json:"rspCrlSupportedVersion,omitempty"`
+	NREPCRelease         *Version `json:"nrEpcSupportedRelease,omitempty"`
+	NR5GCRelease         *Version `json:"nr5gcSupportedRelease,omitempty"`
+	EUTRAN5GCRelease     *Version `json:"eutran5gcSupportedRelease,omitempty"`
+	LPASVN               *Version `json:"lpaSvn,omitempty"`
+	CatSupportedClasses  *Bits    `json:"catSupportedClasses,omitempty"`
+	FormFactor           string   `json:"euiccFormFactorType,omitempty"`
+	AdditionalFeatures   *TLV     `json:"deviceAdditionalFeatureSupport,omitempty"`
+	Raw                  []*TLV   `json:"rawElements,omitempty"`
+}
+
+var (
+	catSupportedClasses = strings.Split("abcdefghijklmnopqrstuvwxyz", "")
+	euiccFormFactors    = []string{"", "removableEuicc", "nonRemovableEuicc", "integratedEuicc", "otherEuicc"}
+)
+
+// deviceReleases in the order of the SGP.22 definition, context tags 0 to 11
+var deviceReleases = []struct {
+	name  string
+	field func(*DeviceCapabilities) **Version
+}{
+	{"gsmSupportedRelease", func(c *DeviceCapabilities) **Version { return &c.GSMRelease }},
+	{"utranSupportedRelease", func(c *DeviceCapabilities) **Version { return &c.UTRANRelease }},
+	{"cdma2000onexSupportedRelease", func(c *DeviceCapabilities) **Version { return &c.CDMA2000OneXRelease }},
+	{"cdma2000hrpdSupportedRelease", func(c *DeviceCapabilities) **Version { return &c.CDMA2000HRPDRelease }},
+	{"cdma2000ehrpdSupportedRelease", func(c *DeviceCapabilities) **Version { return &c.CDMA2000EHRPDRelease }},
+	{"eutranEpcSupportedRelease", func(c *DeviceCapabilities) **Version { return &c.EUTRANEPCRelease }},
+	{"contactlessSupportedRelease", func(c *DeviceCapabilities) **Version { return &c.ContactlessRelease }},
+	{"rspCrlSupportedVersion", func(c *DeviceCapabilities) **Version { return &c.RSPCRLVersion }},
+	{"nrEpcSupportedRelease", func(c *DeviceCapabilities) **Version { return &c.NREPCRelease }},
+	{"nr5gcSupportedRelease", func(c *DeviceCapabilities) **Version { return &c.NR5GCRelease }},
+	{"eutran5gcSupportedRelease", func(c *DeviceCapabilities) **Version { return &c.EUTRAN5GCRelease }},
+	{"lpaSvn", func(c *DeviceCapabilities) **Version { return &c.LPASVN }},
+}
+
+func (d *DeviceInfo) UnmarshalBerTLV(tlv *TLV) error {
+	var info DeviceInfo
+	var problems DecodeErrors
+	problem := func(child *TLV, name string, err error) {
+		problems = append(problems, &DecodeError{Field: "deviceInfo." + name, Tag: child.Tag, Reason: err.Error()})
+		info.Raw = append(info.Raw, child)
+	}
+	decoded := make(map[string]bool)
+	for _, child := range tlv.Children {
+		if decoded[string(child.Tag)] {
+			info.Raw = append(info.Raw, child)
+			continue
+		}
+		decoded[string(child.Tag)] = true
+		switch {
+		case bytes.Equal(child.Tag, Tag{0x80}):
+			if len(child.Value) != 4 {
+				problem(child, "tac", fmt.Errorf("invalid length %d", len(child.Value)))
+			} else {
+				info.TAC = hex.EncodeToString(child.Value)
+			}
+		case bytes.Equal(child.Tag, Tag{0xA1}):
+			if err := info.Capabilities.unmarshal(child); err != nil {
+				problems = append(problems, err...)
+			}
+		case bytes.Equal(child.Tag, Tag{0x82}):
+			var err error
+			if info.IMEI, err = decodeIMEI(child.Value); err != nil {
+				problem(child, "imei", err)
+			}
+		default:
+			info.Raw = append(info.Raw, child)
+		}
+	}
+	for _, required := range []struct {
+		tag  Tag
+		name string
+	}{{Tag{0x80}, "tac"}, {Tag{0xA1}, "deviceCapabilities"}} {
+		if !decoded[string(required.tag)] {
+			problems = append(problems, &DecodeError{Field: "deviceInfo." + required.name, Tag: required.tag, Reason: "missing"})
+		}
+	}
+	*d = info
+	if len(problems) > 0 {
+		return problems
+	}
+	return nil
+}
+
+func (c *DeviceCapabilities) unmarshal(tlv *TLV) (problems DecodeErrors) {
+	problem := func(child *TLV, name string, err error) {
+		problems = append(problems, &DecodeError{Field: "deviceInfo.deviceCapabilities." + name, Tag: child.Tag, Reason: err.Error()})
+		c.Raw = append(c.Raw, child)
+	}
+	decoded := make(map[string]bool)
+	for _, child := range tlv.Children {
+		if decoded[string(child.Tag)] || len(child.Tag) != 1 {
+			c.Raw = append(c.Raw, child)
+			continue
+		}
+		decoded[string(child.Tag)] = true
+		var err error
+		switch number := int(child.Tag[0] & 0x1F); {
+		case child.Tag[0]&0xE0 == 0x80 && number < len(deviceReleases):
+			release := deviceReleases[number]
+			if version, err := decodeVersion(child); err != nil {
+				problem(child, release.name, err)
+			} else {
+				*release.field(c) = &version
+			}
+		case child.Tag[0] == 0x8C:
+			if c.CatSupportedClasses, err = NewBits(child.Value, catSupportedClasses); err != nil {
+				problem(child, "catSupportedClasses", err)
+			}
+		case child.Tag[0] == 0x8D:
+			if c.FormFactor, err = decodeEnum(child.Value, euiccFormFactors); err != nil {
+				problem(child, "euiccFormFactorType", err)
+			}
+		case child.Tag[0] == 0xAE:
+			c.AdditionalFeatures = child
+		default:
+			c.Raw = append(c.Raw, child)
+		}
+	}
+	return
+}
+
+// decodeIMEI reads the swapped nibble BCD of 3GPP TS 24.008, the filler F is dropped
+func decodeIMEI(value []byte) (string, error) {
+	if len(value) != 8 {
+		return "", fmt.Errorf("invalid length %d", len(value))
+	}
+	var digits strings.Builder
+	for index, octet := range value {
+		for _, digit := range []byte{octet & 0x0F, octet >> 4} {
+			switch {
+			case digit < 10:
+				digits.WriteByte('0' + digit)
+			case digit == 0x0F && index == len(value)-1:
+			default:
+				return "", fmt.Errorf("invalid digit %X", digit)
+			}
+		}
+	}
+	return digits.String(), nil
+}
@@ -0,0 +1,72 @@
+package dump
+
+import (
+	"encoding/hex"
+	"errors"
+	. "github.com/euicc-go/bertlv"
+	"slices"
+	"testing"
+)
+
+func TestDeviceInfoUnmarshal(t *testing.T) {
+	// DeviceInfo of ctxParams1: TAC 35290611, gsm 13, utran 15, eutranEpc 16,
+	// rspCrl 2.2.0, lpaSvn 2.3.0, nonRemovableEuicc and IMEI 352906111234567
+	data, _ := hex.DecodeString("A12E800435290611A11C80030D000081030F0000850310000087030202008B030203008D0102820853926011214365F7")
+	tlv := new(TLV)
+	if err := tlv.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	var info DeviceInfo
+	if err := info.UnmarshalBerTLV(tlv); err != nil {
+		t.Fatal(err)
+	}
+	if info.TAC != "35290611" {
+		t.Errorf("tac = %s", info.TAC)
+	}
+	if info.IMEI != "352906111234567" {
+		t.Errorf("imei = %s", info.IMEI)
+	}
+	capabilities := info.Capabilities
+	for name, version := range map[string]*Version{
+		"13.0.0": capabilities.GSMRelease,
+		"15.0.0": capabilities.UTRANRelease,
+		"16.0.0": capabilities.EUTRANEPCRelease,
+		"2.2.0":  capabilities.RSPCRLVersion,
+		"2.3.0":  capabilities.LPASVN,
+	} {
+		if version == nil || version.String() != name {
+			t.Errorf("release = %v, want %s", version, name)
+		}
+	}
+	if capabilities.FormFactor != "nonRemovableEuicc" {
+		t.Errorf("euiccFormFactorType = %s", capabilities.FormFactor)
+	}
+	if capabilities.CDMA2000OneXRelease != nil || len(info.Raw) > 0 || len(capabilities.Raw) > 0 {
+		t.Errorf("unexpected elements: %+v", info)
+	}
+}
+
+func TestDeviceInfoUnmarshalProblems(t *testing.T) {
+	// a TAC of 3 bytes, an IMEI with the digit A and no deviceCapabilities
+	data, _ := hex.DecodeString("A10F8003352906820853926011214365FA")
+	tlv := new(TLV)
+	if err := tlv.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	var info DeviceInfo
+	var problems DecodeErrors
+	if !errors.As(info.UnmarshalBerTLV(tlv), &problems) {
+		t.Fatal("UnmarshalBerTLV accepted an invalid DeviceInfo")
+	}
+	var fields []string
+	for _, problem := range problems {
+		fields = append(fields, problem.Field)
+	}
+	want := []string{"deviceInfo.tac", "deviceInfo.imei", "deviceInfo.deviceCapabilities"}
+	if !slices.Equal(fields, want) {
+		t.Fatalf("problems = %v, want %v", fields, want)
+	}
+	if len(info.Raw) != 2 {
+		t.Fatalf("rawElements = %v, want the invalid tac and imei", info.Raw)
+	}
+}
@@ -0,0 +1,102 @@
+package dump
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"regexp"
+	"strings"
+)
+
+//go:embed eum-catalog.json
+var defaultEUMCatalog []byte
+
+var (
+	eidPattern       = regexp.MustCompile(`^89[0-9]{30}$`)
+	einPrefixPattern = regexp.MustCompile(`^89[0-9]{0,28}$`)
+)
+
+var eumCatalog = newCatalog("eum", defaultEUMCatalog, func(eum EUM) string { return eum.Prefix }, validateEUMCatalog)
+
+// EID is the eUICC identifier of GSMA SGP.29, 32 digits:
+// 89, country code (3), issuer identifier (3), version (5),
+// additional issuer information (5), individual identification number (12) and check digits (2).
+type EID struct {
+	Value                string `json:"eid"`
+	EIN                  string `json:"ein"`
+	CountryCode          string `json:"countryCode"`
+	IssuerId             string `json:"issuerId"`
+	Version              string `json:"version"`
+	AdditionalIssuerInfo string `json:"additionalIssuerInfo"`
+	IndividualId         string `json:"individualIdentificationNumber"`
+	CheckDigits          string `json:"checkDigits"`
+	Valid                bool   `json:"valid"`
+	Manufacturer         string `json:"manufacturer,omitempty"`
+}
+
+// ParseEID decodes the EID, a wrong check digit still returns the decoded EID with an error
+func ParseEID(value string) (*EID, error) {
+	if !eidPattern.MatchString(value) {
+		return nil, fmt.Errorf("%q is not 32 digits starting with 89", value)
+	}
+	eid := &EID{
+		Value:                value,
+		EIN:                  value[0:8],
+		CountryCode:          value[2:5],
+		IssuerId:             value[5:8],
+		Version:              value[8:13],
+		AdditionalIssuerInfo: value[13:18],
+		IndividualId:         value[18:30],
+		CheckDigits:          value[30:32],
+		Manufacturer:         LookupEUM(value),
+	}
+	// ISO 7064 MOD 97-10, the whole EID modulo 97 is 1
+	number, _ := new(big.Int).SetString(value, 10)
+	if eid.Valid = number.Mod(number, big.NewInt(97)).Int64() == 1; !eid.Valid {
+		return eid, fmt.Errorf("invalid check digits %s", eid.CheckDigits)
+	}
+	return eid, nil
+}
+
+type EUM struct {
+	Prefix string `json:"prefix"`
+	Name   string `json:"name"`
+}
+
+// EUMCatalog names the eUICC manufacturers by EID prefix, the longest prefix wins
+type EUMCatalog []EUM
+
+func ReadEUMCatalog(r io.Reader) (EUMCatalog, error) {
+	return eumCatalog.read(r)
+}
+
+// SetEUMCatalog names more manufacturers than the bundled EUM catalog,
+// an entry repeating a bundled prefix renames that manufacturer.
+func SetEUMCatalog(catalog EUMCatalog) error {
+	return eumCatalog.set(catalog)
+}
+
+func validateEUMCatalog(catalog []EUM) error {
+	for _, eum := range catalog {
+		if !einPrefixPattern.MatchString(eum.Prefix) {
+			return fmt.Errorf("invalid prefix %q", eum.Prefix)
+		}
+		if eum.Name == "" {
+			return errors.New("empty name for prefix " + eum.Prefix)
+		}
+	}
+	return nil
+}
+
+// LookupEUM returns the manufacturer of the EID, or an empty string if it is unknown
+func LookupEUM(eid string) (name string) {
+	var matched string
+	for _, eum := range eumCatalog.load() {
+		if strings.HasPrefix(eid, eum.Prefix) && len(eum.Prefix) > len(matched) {
+			matched, name = eum.Prefix, eum.Name
+		}
+	}
+	return
+}
@@ -1,7 +1,77 @@
 package dump
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	. "github.com/CursedHardware/go-rsp-dump/rsp/types"
+	"slices"
+)
 
 var (
-	errNotFound = errors.New("rsp-dump: no supported RSP server found")
+	errNotFound         = newError("8.8.2", "3.1", "rsp-dump: no supported RSP server found")
+	errMalformedRequest = newError("1.1", "2.1", "rsp-dump: malformed request body")
+	errMissingElement   = newError("1.1", "2.2", "rsp-dump: mandatory element missing")
 )
+
+// hostErrors are InitiateAuthentication answers that depend on the SM-DP+ rather than on the eUICC,
+// the next host of the issuer is tried on them
+var hostErrors = []*Error{
+	newError("8.8.1", "3.8", "invalidDpAddress"),
+	newError("8.8.2", "3.1", "ciPKNotSupported"),
+	newError("8.8.3", "3.1", "euiccVersionNotSupportedByDp"),
+}
+
+func isHostError(err error) bool {
+	return slices.ContainsFunc(hostErrors, func(target *Error) bool { return errors.Is(err, target) })
+}
+
+// ASN.1 error CHOICE values, keyed by "subjectCode/reasonCode"
+var (
+	initAuthenErrorCodes = map[string]byte{
+		"8.8.1/3.8": 1, // invalidDpAddress
+		"8.8.3/3.1": 2, // euiccVersionNotSupportedByDp
+		"8.8.2/3.1": 3, // ciPKNotSupported
+	}
+	authenClientErrorCodes = map[string]byte{
+		"8.1.2/6.1":  1,  // eumCertificateInvalid
+		"8.1.2/6.3":  2,  // eumCertificateExpired
+		"8.1.3/6.1":  3,  // euiccCertificateInvalid
+		"8.1.3/6.3":  4,  // euiccCertificateExpired
+		"8.1/6.1":    5,  // euiccSignatureInvalid
+		"8.2.6/3.8":  6,  // matchingIdRefused
+		"8.1.1/3.8":  7,  // eidMismatch
+		"8.2.5/4.3":  8,  // noEligibleProfile
+		"8.11.1/3.9": 9,  // ciPKUnknown
+		"8.10.1/3.9": 10, // invalidTransactionId
+		"8.1.1/3.1":  11, // insufficientMemory
+	}
+	cancelSessionErrorCodes = map[string]byte{
+		"8.10.1/3.9": 1, // invalidTransactionId
+		"8.1/6.1":    2, // euiccSignatureInvalid
+	}
+)
+
+func newError(subjectCode, reasonCode, format string, a ...any) *Error {
+	return &Error{
+		Status:      Failed,
+		SubjectCode: subjectCode,
+		ReasonCode:  reasonCode,
+		Message:     fmt.Sprintf(format, a...),
+	}
+}
+
+func toError(err error) *Error {
+	var _err *Error
+	if !errors.As(err, &_err) {
+		_err = newError("1.1", "1.1", "%s", err.Error())
+	}
+	return _err
+}
+
+func errorCode(err error, codes map[string]byte) byte {
+	_err := toError(err)
+	if code, ok := codes[_err.SubjectCode+"/"+_err.ReasonCode]; ok {
+		return code
+	}
+	return 127 // undefinedError
+}
@@ -0,0 +1,43 @@
+package dump
+
+import (
+	"bytes"
+	. "github.com/euicc-go/bertlv"
+	"testing"
+)
+
+func TestAuthenClientErrorCodes(t *testing.T) {
+	// AuthenticateClientResponseEs9 authenticateClientError of SGP.22
+	cases := []struct {
+		name                    string
+		subjectCode, reasonCode string
+		code                    byte
+	}{
+		{"eumCertificateInvalid", "8.1.2", "6.1", 1},
+		{"eumCertificateExpired", "8.1.2", "6.3", 2},
+		{"euiccCertificateInvalid", "8.1.3", "6.1", 3},
+		{"euiccCertificateExpired", "8.1.3", "6.3", 4},
+		{"euiccSignatureInvalid", "8.1", "6.1", 5},
+		{"matchingIdRefused", "8.2.6", "3.8", 6},
+		{"eidMismatch", "8.1.1", "3.8", 7},
+		{"noEligibleProfile", "8.2.5", "4.3", 8},
+		{"ciPKUnknown", "8.11.1", "3.9", 9},
+		{"invalidTransactionId", "8.10.1", "3.9", 10},
+		{"insufficientMemory", "8.1.1", "3.1", 11},
+		{"undefinedError", "1.1", "1.1", 127},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := newError(c.subjectCode, c.reasonCode, "%s", c.name)
+			if code := errorCode(err, authenClientErrorCodes); code != c.code {
+				t.Fatalf("errorCode = %d, want %d", code, c.code)
+			}
+			expected := NewChildren(Tag{0xBF, 0x3B}, NewValue(Tag{0x81}, []byte{c.code}))
+			got, _ := encodeAuthenClientASN1(nil, err).MarshalBinary()
+			want, _ := expected.MarshalBinary()
+			if !bytes.Equal(got, want) {
+				t.Fatalf("encodeAuthenClientASN1 = %X, want %X", got, want)
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package dump
 
 import (
 	"bytes"
+	"context"
 	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
@@ -10,19 +11,41 @@ import (
 	. "github.com/euicc-go/bertlv"
 	"github.com/pkg/errors"
 	"log"
-	"math/rand/v2"
 	"net/http"
-	"net/url"
 	"regexp"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 type Handler struct {
 	Homepage       string
 	Client         *http.Client
-	Issuers        map[string][]string
+	Issuers        Registry
 	HostPattern    *regexp.Regexp
-	OnAuthenClient func(*TLV) error
+	Policy         IssuerPolicy
+	Sessions       SessionStore
+	Signer         *LocalSigner
+	CIs            CIBundle
+	Health         *HealthTracker
+	OnAuthenClient func(*Session, *TLV) error
+	sessionsOnce   sync.Once
+	healthOnce     sync.Once
+	router         *Router
+	routerOnce     sync.Once
+	routing        atomic.Pointer[routing]
+	probes         atomic.Pointer[ProbeReport]
+	cis            atomic.Pointer[CIBundle]
+	cancels        sync.WaitGroup
+}
+
+var keyIdPrefixPattern = regexp.MustCompile(`^[a-f0-9]{6,40}$`)
+
+type routing struct {
+	issuers     Registry
+	hostPattern *regexp.Regexp
+	policy      IssuerPolicy
 }
 
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -38,85 +61,216 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
 		return
 	}
+	protocol := r.Header.Get("X-Admin-Protocol")
+	responseProtocol := adminProtocolPrefix + defaultAdminProtocol.String()
 	var err error
-	encoder := json.NewEncoder(w)
-	decoder := json.NewDecoder(r.Body)
-	switch strings.TrimPrefix(r.URL.Path, "/gsma/rsp2") {
-	case "/asn1":
-		var request *TLV
-		if _, err = request.ReadFrom(r.Body); err == nil {
-			_, _ = h.handleASN1(request).WriteTo(w)
-		}
-	case "/es9plus/initiateAuthentication":
-		var request InitAuthenRequest
-		var response *InitAuthenResponse
-		if err = decoder.Decode(&request); err != nil {
-			goto errorHandling
-		}
-		if response, err = h.handleInitAuthen(&request); err == nil {
-			_ = encoder.Encode(response)
-		}
-	case "/es9plus/authenticateClient":
-		var request AuthenClientRequest
-		var response *GeneralResponse
-		if err = decoder.Decode(&request); err != nil {
-			goto errorHandling
-		}
-		if response, err = h.handleAuthenClient(&request); err == nil {
-			_ = encoder.Encode(response)
-		}
-	}
-errorHandling:
-	if err != nil {
-		var _err *Error
-		if !errors.As(err, &_err) {
-			_err = &Error{Status: Failed, SubjectCode: "1.1", ReasonCode: "1.1", Message: err.Error()}
+	if protocol != "" {
+		var version Version
+		if version, err = parseAdminProtocol(protocol); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if supportedAdminProtocol(version) {
+			responseProtocol = protocol
+		} else {
+			responseProtocol = adminProtocolPrefix + maxAdminProtocol.String()
+			err = newError("8.8.3", "3.1", "unsupported X-Admin-Protocol: %q", protocol)
 		}
-		_ = encoder.Encode(_err)
+	}
+	w.Header().Set("X-Admin-Protocol", responseProtocol)
+	path := strings.TrimPrefix(r.URL.Path, "/gsma/rsp2")
+	switch {
+	case err != nil && path == "/asn1":
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case err != nil:
+		w.Header().Set("Content-Type", "application/json;charset=UTF-8")
+		_ = json.NewEncoder(w).Encode(toError(err))
+	case path == "/asn1":
+		h.Router().serveASN1(w, r, protocol)
+	default:
+		h.Router().serveJSON(w, r, path, protocol)
 	}
 }
 
-func (h *Handler) handleInitAuthen(r *InitAuthenRequest) (resp *InitAuthenResponse, err error) {
-	u := &url.URL{Scheme: "https", Path: "/gsma/rsp2/es9plus/initiateAuthentication"}
+func (h *Handler) Router() *Router {
+	h.routerOnce.Do(func() {
+		h.router = NewRouter()
+		Handle(h.router, Route[InitAuthenRequest, InitAuthenResponse]{
+			Path: "/es9plus/initiateAuthentication",
+			Tag:  Tag{0xBF, 0x39},
+			Handle: func(request *Request, r *InitAuthenRequest) (*InitAuthenResponse, error) {
+				r.AdminProtocol = request.AdminProtocol
+				return h.handleInitAuthen(request.HTTP.Context(), r)
+			},
+			DecodeASN1: decodeInitAuthenASN1,
+			EncodeASN1: encodeInitAuthenASN1,
+		})
+		Handle(h.router, Route[AuthenClientRequest, GeneralResponse]{
+			Path: "/es9plus/authenticateClient",
+			Tag:  Tag{0xBF, 0x3B},
+			Handle: func(_ *Request, r *AuthenClientRequest) (*GeneralResponse, error) {
+				return h.handleAuthenClient(r)
+			},
+			DecodeASN1: decodeAuthenClientASN1,
+			EncodeASN1: encodeAuthenClientASN1,
+		})
+		Handle(h.router, Route[CancelSessionRequest, GeneralResponse]{
+			Path: "/es9plus/cancelSession",
+			Tag:  Tag{0xBF, 0x41},
+			Handle: func(_ *Request, r *CancelSessionRequest) (*GeneralResponse, error) {
+				return h.handleCancelSession(r)
+			},
+			DecodeASN1: decodeCancelSessionASN1,
+			EncodeASN1: encodeCancelSessionASN1,
+		})
+		// on the ASN.1 binding the ES11 requests carry the same BF39 and BF3B as ES9+ and cannot
+		// be told apart, the ES9+ routes above answer them and the ES11 routes are JSON only
+		Handle(h.router, Route[SMDSInitAuthenRequest, InitAuthenResponse]{
+			Path: "/es11/initiateAuthentication",
+			Handle: func(request *Request, r *SMDSInitAuthenRequest) (*InitAuthenResponse, error) {
+				r.AdminProtocol = request.AdminProtocol
+				return h.handleSMDSInitAuthen(request.HTTP.Context(), r)
+			},
+		})
+		Handle(h.router, Route[AuthenClientRequest, GeneralResponse]{
+			Path: "/es11/authenticateClient",
+			Handle: func(_ *Request, r *AuthenClientRequest) (*GeneralResponse, error) {
+				return h.handleAuthenClient(r)
+			},
+		})
+	})
+	return h.router
+}
+
+func (h *Handler) handleInitAuthen(ctx context.Context, r *InitAuthenRequest) (resp *InitAuthenResponse, err error) {
+	if r.Info1 == nil || r.Info1.First(Tag{0x82}) == nil {
+		return nil, errMissingElement
+	}
+	if h.Signer != nil {
+		return h.handleLocalInitAuthen(r)
+	}
 	var issuer []byte
-	if issuer, u.Host, err = h.findHost(r); err != nil {
+	var hosts []RegistryHost
+	if issuer, hosts, err = h.findHost(r); err != nil {
 		return
 	}
-	svn := r.Info1.First(Tag{0x82})
-	r.Info1 = NewChildren(
+	var signingV3 *TLV
+	if hasKeyId(r.Info1.First(Tag{0xB1}), issuer) {
+		signingV3 = NewChildren(Tag{0xB1}, NewValue(Tag{0x04}, issuer))
+	}
+	forward := *r
+	forward.Info1 = NewChildren(
 		r.Info1.Tag,
-		svn,
+		r.Info1.First(Tag{0x82}),
 		NewChildren(Tag{0xA9}, NewValue(Tag{0x04}, issuer)),
 		NewChildren(Tag{0xAA}, NewValue(Tag{0x04}, issuer)),
+		r.Info1.First(Tag{0x93}),
+		signingV3,
+		r.Info1.First(Tag{0x88}),
 	)
-	r.Address = u.Host
-	body, _ := json.Marshal(r)
-	request, _ := http.NewRequest(http.MethodPost, u.String(), bytes.NewReader(body))
-	request.Header.Set("User-Agent", "gsma-rsp-lpad")
-	request.Header.Set("Content-Type", "application/json")
-	request.Header.Set("X-Admin-Protocol", fmt.Sprintf("gsma/rsp/v%d.%d.%d", svn.Value[0], svn.Value[1], svn.Value[2]))
-	response, err := h.Client.Do(request)
-	if err != nil {
-		return
+	ctx, cancel := context.WithTimeout(ctx, DefaultFailoverTimeout)
+	defer cancel()
+	for attempt, host := range h.probeOrder(issuer, h.health().Order(hosts)) {
+		if attempt == DefaultFailoverAttempts || ctx.Err() != nil {
+			break
+		}
+		session := &Session{
+			Host:             host.Endpoint(),
+			Operator:         host.Name,
+			Issuer:           issuer,
+			Challenge:        r.Challenge,
+			Info1:            r.Info1, // untouched, forward carries the reduced copy
+			LPARSPCapability: r.LPARSPCapability,
+			AdminProtocol:    host.protocol(negotiateProtocol(r.AdminProtocol, r.Info1)),
+		}
+		var retry bool
+		if resp, retry, err = h.initAuthenUpstream(ctx, &forward, session, host.timeout()); err == nil || !retry {
+			return
+		}
 	}
+	return
+}
+
+func (h *Handler) initAuthenUpstream(ctx context.Context, r *InitAuthenRequest, session *Session, timeout time.Duration) (resp *InitAuthenResponse, retry bool, err error) {
+	host := session.Host
+	r.Address = host
 	resp = new(InitAuthenResponse)
-	if err = json.NewDecoder(response.Body).Decode(resp); err != nil {
-		return
+	if timeout <= 0 {
+		timeout = DefaultUpstreamTimeout
 	}
-	if resp.UsedIssuer == nil || !bytes.Equal(issuer, resp.UsedIssuer.Value) {
-		err = fmt.Errorf("InitiateAuthenticationResponse: issuer is mismatch (%s)", r.Address)
-		return
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	start := time.Now()
+	if err = h.invoke(ctx, host, "initiateAuthentication", session.AdminProtocol, r, resp); err != nil {
+		log.Println("ES9+.InitiateAuthenticationResponse", "Host:", host, "Error:", err)
+		h.health().Failure(host, err)
+		return nil, true, err
+	}
+	latency := time.Since(start)
+	session.TransactionId = resp.TransactionId
+	if err = resp.Header.Err(); err != nil {
+		log.Println("ES9+.InitiateAuthenticationResponse", "Host:", host, "Error:", err)
+		h.health().Success(host, latency)
+		return nil, isHostError(err), err
+	}
+	cis := h.currentCIs()
+	if resp.UsedIssuer == nil || !bytes.Equal(session.Issuer, resp.UsedIssuer.Value) {
+		err = newError("8.8.2", "3.1", "InitiateAuthenticationResponse: issuer is mismatch (%s)", host)
+	} else if err = verifyInitAuthen(r, resp, session.Issuer, cis.lookup(session.Issuer), time.Now()); err != nil {
+		log.Println("ES9+.InitiateAuthenticationResponse", "TransactionId:", resp.TransactionId, "Host:", host, "Verification:", err)
+		// without a CI bundle no chain can be verified, the finding above is all there is to report
+		if errors.Is(err, errUnknownCI) && len(cis) == 0 {
+			err = nil
+		} else {
+			err = newError("8.8", "6.1", "InitiateAuthenticationResponse: %s (%s)", err, host)
+		}
+	}
+	if err != nil {
+		h.health().Failure(host, err)
+		if session.TransactionId != "" {
+			h.cancelInBackground(session)
+		}
+		return nil, true, err
 	}
+	h.health().Success(host, latency)
 	log.Println(
 		"ES9+.InitiateAuthenticationResponse",
 		"TransactionId:", resp.TransactionId,
-		"Host:", u.Host,
-		"Issuer:", hex.EncodeToString(issuer),
+		"Host:", host,
+		"Operator:", session.Operator,
+		"Issuer:", hex.EncodeToString(session.Issuer),
+		"CI:", CIName(session.Issuer),
 	)
-	return
+	if err := h.sessions().Store(session); err != nil {
+		log.Println("ES9+.InitiateAuthenticationResponse", "TransactionId:", resp.TransactionId, "Session:", err)
+	}
+	return resp, false, nil
+}
+
+func (h *Handler) handleSMDSInitAuthen(ctx context.Context, r *SMDSInitAuthenRequest) (*InitAuthenResponse, error) {
+	log.Println("ES11.InitiateAuthenticationRequest", "Address:", r.Address)
+	return h.handleInitAuthen(ctx, &InitAuthenRequest{
+		Challenge:        r.Challenge,
+		Address:          r.Address,
+		Info1:            r.Info1,
+		LPARSPCapability: r.LPARSPCapability,
+		AdminProtocol:    r.AdminProtocol,
+	})
 }
 
 func (h *Handler) handleAuthenClient(r *AuthenClientRequest) (_ *GeneralResponse, err error) {
+	if r.Response == nil || len(r.Response.Children) == 0 {
+		return nil, errMissingElement
+	}
+	response := r.Response.At(0)
+	var errorCode byte
+	if response.Tag[0] == 0xA1 {
+		code := response.First(Tag{0x02})
+		if code == nil || len(code.Value) == 0 {
+			return nil, errMissingElement
+		}
+		errorCode = code.Value[0]
+	}
 	if data, _ := r.Response.MarshalBinary(); len(data) > 0 {
 		log.Println(
 			"ES9+.AuthenticateClientRequest",
@@ -124,9 +278,16 @@ func (h *Handler) handleAuthenClient(r *AuthenClientRequest) (_ *GeneralResponse
 			"Response:", base64.StdEncoding.EncodeToString(data),
 		)
 	}
-	switch response := r.Response.At(0); response.Tag[0] {
+	session, ok := h.sessions().Load(r.TransactionId)
+	if ok {
+		r.TransactionId = session.TransactionId
+	} else {
+		log.Println("ES9+.AuthenticateClientRequest", "TransactionId:", r.TransactionId, "Session: not found")
+		session = &Session{TransactionId: r.TransactionId}
+	}
+	switch response.Tag[0] {
 	case 0xA0: // AuthenticateResponseOk
-		if err = h.OnAuthenClient(response); err == nil {
+		if err = h.OnAuthenClient(session, response); err == nil {
 			err = errors.New("AuthenticateResponseOk: extract information finished")
 		}
 	case 0xA1: // AuthenticateResponseError
@@ -139,7 +300,6 @@ func (h *Handler) handleAuthenClient(r *AuthenClientRequest) (_ *GeneralResponse
 			6: "euiccChallengeMismatch",
 			7: "ciPKUnknown",
 		}
-		errorCode := response.First(Tag{0x02}).Value[0]
 		err = fmt.Errorf("AuthenticateResponseError: undefinedError (%d)", errorCode)
 		if errorMessage, ok := errorCodes[errorCode]; ok {
 			err = fmt.Errorf("AuthenticateResponseError: %s (%d)", errorMessage, errorCode)
@@ -147,77 +307,156 @@ func (h *Handler) handleAuthenClient(r *AuthenClientRequest) (_ *GeneralResponse
 	default:
 		err = errors.New("ES10b#AuthenticateServer: An unknown error occurred")
 	}
+	h.cancelInBackground(session)
 	return
 }
 
-func (h *Handler) handleASN1(request *TLV) *TLV {
-	if r := request.First(Tag{0xBF, 0x39}); r != nil {
-		authen, err := h.handleInitAuthen(&InitAuthenRequest{
-			Challenge: r.First(Tag{0x81}).Value,
-			Address:   string(r.First(Tag{0x83}).Value),
-			Info1:     r.First(Tag{0xBF, 0x20}),
-		})
-		if err != nil {
-			return nil
-		}
-		transactionId, err := hex.DecodeString(authen.TransactionId)
-		if err != nil {
-			return nil
-		}
-		return NewChildren(Tag{0xBF, 0x39}, NewChildren(
-			Tag{0xA0},
-			NewValue(Tag{0x80}, transactionId),
-			authen.Signed1,
-			authen.Signature1,
-			authen.UsedIssuer,
-			authen.Certificate,
-		))
-	}
-	if r := request.First(Tag{0xBF, 0x3B}); r != nil {
-		_, _ = h.handleAuthenClient(&AuthenClientRequest{
-			TransactionId: hex.EncodeToString(r.First(Tag{0x80}).Value),
-			Response:      r.First(Tag{0xBF, 0x38}),
-		})
+func (h *Handler) handleCancelSession(r *CancelSessionRequest) (_ *GeneralResponse, err error) {
+	if r.Response == nil {
+		return nil, errMissingElement
 	}
-	return nil
+	if data, _ := r.Response.MarshalBinary(); len(data) > 0 {
+		log.Println(
+			"ES9+.CancelSessionRequest",
+			"TransactionId:", r.TransactionId,
+			"Response:", base64.StdEncoding.EncodeToString(data),
+		)
+	}
+	if session, ok := h.sessions().Load(r.TransactionId); ok {
+		h.cancelUpstream(session, r.Response)
+	}
+	return &GeneralResponse{Header: ExecutedSuccess.Header(nil)}, nil
 }
 
-func (h *Handler) findHost(r *InitAuthenRequest) (issuer []byte, host string, err error) {
-	if h.HostPattern == nil {
-		return h.findBestMatchHost(r)
-	}
-	index := h.HostPattern.SubexpIndex("issuer")
-	matches := h.HostPattern.FindStringSubmatch(r.Address)
-	if index == -1 || matches == nil {
-		return h.findBestMatchHost(r)
+func (h *Handler) health() *HealthTracker {
+	h.healthOnce.Do(func() {
+		if h.Health == nil {
+			h.Health = NewHealthTracker()
+		}
+	})
+	return h.Health
+}
+
+func (h *Handler) sessions() SessionStore {
+	h.sessionsOnce.Do(func() {
+		if h.Sessions == nil {
+			h.Sessions = NewMemorySessionStore(DefaultSessionTTL)
+		}
+	})
+	return h.Sessions
+}
+
+// Update atomically replaces the issuer registry, host pattern and issuer policy,
+// concurrent requests observe either the previous or the new set.
+func (h *Handler) Update(issuers Registry, hostPattern *regexp.Regexp, policy IssuerPolicy) {
+	h.routing.Store(&routing{issuers: issuers, hostPattern: hostPattern, policy: policy})
+}
+
+// SetProbeReport makes host selection try the hosts failing their last probe last
+func (h *Handler) SetProbeReport(report *ProbeReport) {
+	h.probes.Store(report)
+}
+
+// SetCIBundle replaces the CIs that InitiateAuthentication responses are verified against,
+// requests already relayed keep the bundle they started with
+func (h *Handler) SetCIBundle(bundle CIBundle) {
+	h.cis.Store(&bundle)
+}
+
+func (h *Handler) currentCIs() CIBundle {
+	if bundle := h.cis.Load(); bundle != nil {
+		return *bundle
 	}
-	return h.findSpecificHost(strings.ToLower(matches[index]))
+	return h.CIs
 }
 
-func (h *Handler) findBestMatchHost(r *InitAuthenRequest) (issuer []byte, host string, err error) {
-	err = errNotFound
-	for _, child := range r.Info1.First(Tag{0xAA}).Children {
-		if hosts, ok := h.Issuers[hex.EncodeToString(child.Value)]; ok {
-			issuer = child.Value
-			host = hosts[rand.IntN(len(hosts))]
-			err = nil
-			return
+func (h *Handler) probeOrder(issuer []byte, hosts []RegistryHost) []RegistryHost {
+	report := h.probes.Load()
+	if report == nil {
+		return hosts
+	}
+	keyId := hex.EncodeToString(issuer)
+	var passed, failed []RegistryHost
+	for _, host := range hosts {
+		if result, ok := report.Lookup(keyId, host.Endpoint()); ok && !result.OK() {
+			failed = append(failed, host)
+		} else {
+			passed = append(passed, host)
 		}
 	}
+	return append(passed, failed...)
+}
+
+func (h *Handler) currentRouting() *routing {
+	if current := h.routing.Load(); current != nil {
+		return current
+	}
+	h.routing.CompareAndSwap(nil, &routing{issuers: h.Issuers, hostPattern: h.HostPattern, policy: h.Policy})
+	return h.routing.Load()
+}
+
+func (h *Handler) findHost(r *InitAuthenRequest) (issuer []byte, hosts []RegistryHost, err error) {
+	current := h.currentRouting()
+	var matches []string
+	if current.hostPattern != nil {
+		matches = current.hostPattern.FindStringSubmatch(r.Address)
+	}
+	prefix := current.submatch(matches, "issuer")
+	if ci := LookupCIAlias(prefix); ci != nil {
+		prefix = ci.KeyId.String()
+	}
+	switch {
+	case keyIdPrefixPattern.MatchString(prefix):
+		issuer, hosts, err = current.findSpecificHost(r, prefix)
+	case prefix == IssuerClassProduction || prefix == IssuerClassTest:
+		issuer, hosts, err = current.findBestMatchHost(r, prefix)
+	default:
+		issuer, hosts, err = current.findBestMatchHost(r, "")
+	}
+	if alias := current.submatch(matches, "smdp"); err == nil && alias != "" {
+		hosts, err = pinHost(issuer, hosts, alias)
+	}
 	return
 }
 
-func (h *Handler) findSpecificHost(prefix string) (issuer []byte, host string, err error) {
-	err = errNotFound
-	if len(prefix) == 0 {
-		return
+func (rt *routing) submatch(matches []string, name string) string {
+	if matches == nil {
+		return ""
 	}
-	for keyId, hosts := range h.Issuers {
-		if strings.HasPrefix(keyId, prefix) {
-			issuer, _ = hex.DecodeString(keyId)
-			host = hosts[rand.IntN(len(hosts))]
-			err = nil
-			return
+	if index := rt.hostPattern.SubexpIndex(name); index != -1 {
+		return strings.ToLower(matches[index])
+	}
+	return ""
+}
+
+// pinHost keeps the host whose registry alias is requested through the smdp group of HostPattern
+func pinHost(issuer []byte, hosts []RegistryHost, alias string) ([]RegistryHost, error) {
+	var choices []string
+	for _, host := range hosts {
+		if host.Alias == alias {
+			return []RegistryHost{host}, nil
+		}
+		if host.Alias != "" {
+			choices = append(choices, host.Alias)
+		}
+	}
+	if len(choices) == 0 {
+		return nil, newError(errNotFound.SubjectCode, errNotFound.ReasonCode, "SM-DP+ %q is not available for issuer %x, no aliased SM-DP+ for this issuer", alias, issuer)
+	}
+	return nil, newError(errNotFound.SubjectCode, errNotFound.ReasonCode, "SM-DP+ %q is not available for issuer %x, valid choices: %s", alias, issuer, strings.Join(choices, ", "))
+}
+
+// candidates returns the enabled hosts of the issuer accepting the eUICC SVN range
+func (rt *routing) candidates(keyId string, info1 *TLV) (hosts []RegistryHost) {
+	var lowest Version
+	copy(lowest[:], info1.First(Tag{0x82}).Value)
+	highest := lowest
+	if version := info1.First(Tag{0x93}); version != nil && len(version.Value) == 3 {
+		highest = Version(version.Value)
+	}
+	for _, host := range rt.issuers[keyId] {
+		if host.Supports(lowest, highest) {
+			hosts = append(hosts, host)
 		}
 	}
 	return
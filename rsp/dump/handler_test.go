@@ -0,0 +1,206 @@
+package dump
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	. "github.com/CursedHardware/go-rsp-dump/rsp/types"
+	. "github.com/euicc-go/bertlv"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestES11InitiateAuthenticationUnknownIssuer(t *testing.T) {
+	// euiccInfo1 listing a CI that no registered SM-DP+ is issued by
+	body := `{"euiccChallenge":"AAECAwQFBgcICQoLDA0ODw==","smdsAddress":"lpa.ds.gsma.com","euiccInfo1":"vyAVggMCAgCpBgQEAQIDBKoGBAQBAgME"}`
+	request := httptest.NewRequest(http.MethodPost, "/gsma/rsp2/es11/initiateAuthentication", bytes.NewBufferString(body))
+	recorder := httptest.NewRecorder()
+	handler := &Handler{Issuers: Registry{"81370f5125d0b1d408d4c3b232e6d25e795bebfb": {{Address: "smdp.example.com"}}}}
+	handler.ServeHTTP(recorder, request)
+	var response GeneralResponse
+	if err := json.NewDecoder(recorder.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	if err := response.Header.Err(); !errors.Is(err, errNotFound) || err.Error() != errNotFound.Error() {
+		t.Fatalf("functionExecutionStatus = %v, want %v", err, errNotFound)
+	}
+}
+
+func TestES11InitiateAuthenticationASN1(t *testing.T) {
+	// an ES11 request is the same InitiateAuthenticationRequest as ES9+ with the SM-DS address,
+	// on /gsma/rsp2/asn1 the ES9+ route answers it
+	challenge, _ := base64.StdEncoding.DecodeString("AAECAwQFBgcICQoLDA0ODw==")
+	info1, _ := base64.StdEncoding.DecodeString("vyAVggMCAgCpBgQEAQIDBKoGBAQBAgME")
+	euiccInfo1 := new(TLV)
+	if err := euiccInfo1.UnmarshalBinary(info1); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := NewChildren(
+		Tag{0xBF, 0x39},
+		NewValue(Tag{0x81}, challenge),
+		NewValue(Tag{0x83}, []byte("lpa.ds.gsma.com")),
+		euiccInfo1,
+	).MarshalBinary()
+	request := httptest.NewRequest(http.MethodPost, "/gsma/rsp2/asn1", bytes.NewReader(data))
+	recorder := httptest.NewRecorder()
+	handler := &Handler{Issuers: Registry{"81370f5125d0b1d408d4c3b232e6d25e795bebfb": {{Address: "smdp.example.com"}}}}
+	handler.ServeHTTP(recorder, request)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", recorder.Code, http.StatusOK)
+	}
+	want, _ := NewChildren(Tag{0xBF, 0x39}, NewValue(Tag{0x81}, []byte{initAuthenErrorCodes["8.8.2/3.1"]})).MarshalBinary()
+	if !bytes.Equal(recorder.Body.Bytes(), want) {
+		t.Fatalf("body = %X, want the ciPKNotSupported CHOICE %X", recorder.Body.Bytes(), want)
+	}
+}
+
+func TestAuthenClientMissingElement(t *testing.T) {
+	cases := map[string]*TLV{
+		"empty authenticateServerResponse": NewChildren(Tag{0xBF, 0x38}),
+		"error without authenticateErrorCode": NewChildren(Tag{0xBF, 0x38}, NewChildren(
+			Tag{0xA1},
+			NewValue(Tag{0x80}, []byte{0x01}),
+		)),
+	}
+	for name, response := range cases {
+		t.Run(name, func(t *testing.T) {
+			data, _ := response.MarshalBinary()
+			body := fmt.Sprintf(`{"transactionId":"01","authenticateServerResponse":%q}`, base64.StdEncoding.EncodeToString(data))
+			request := httptest.NewRequest(http.MethodPost, "/gsma/rsp2/es9plus/authenticateClient", bytes.NewBufferString(body))
+			recorder := httptest.NewRecorder()
+			new(Handler).ServeHTTP(recorder, request)
+			if recorder.Code != http.StatusBadRequest {
+				t.Fatalf("status = %d, want %d: %s", recorder.Code, http.StatusBadRequest, recorder.Body)
+			}
+		})
+	}
+}
+
+func TestASN1MissingElement(t *testing.T) {
+	// InitiateAuthenticationRequest without euiccChallenge and euiccInfo1
+	data, _ := NewChildren(Tag{0xBF, 0x39}, NewValue(Tag{0x83}, []byte("smdp.example.com"))).MarshalBinary()
+	request := httptest.NewRequest(http.MethodPost, "/gsma/rsp2/asn1", bytes.NewReader(data))
+	recorder := httptest.NewRecorder()
+	new(Handler).ServeHTTP(recorder, request)
+	if recorder.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d", recorder.Code, http.StatusBadRequest)
+	}
+	want, _ := NewChildren(Tag{0xBF, 0x39}, NewValue(Tag{0x81}, []byte{127})).MarshalBinary()
+	if !bytes.Equal(recorder.Body.Bytes(), want) {
+		t.Fatalf("body = %X, want the undefinedError CHOICE %X", recorder.Body.Bytes(), want)
+	}
+}
+
+func TestAuthenClientCancelsInBackground(t *testing.T) {
+	release := make(chan struct{})
+	cancelled := make(chan CancelSessionRequest, 1)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/gsma/rsp2/es9plus/cancelSession", func(w http.ResponseWriter, r *http.Request) {
+		var request CancelSessionRequest
+		_ = json.NewDecoder(r.Body).Decode(&request)
+		<-release
+		cancelled <- request
+		_ = json.NewEncoder(w).Encode(&GeneralResponse{Header: ExecutedSuccess.Header(nil)})
+	})
+	upstream := httptest.NewTLSServer(mux)
+	defer upstream.Close()
+	handler := &Handler{Client: upstream.Client()}
+	if err := handler.sessions().Store(&Session{TransactionId: "0A0B", Host: upstream.Listener.Addr().String()}); err != nil {
+		t.Fatal(err)
+	}
+	// AuthenticateResponseError with euiccChallengeMismatch
+	data, _ := NewChildren(Tag{0xBF, 0x38}, NewChildren(
+		Tag{0xA1},
+		NewValue(Tag{0x80}, []byte{0x01}),
+		NewValue(Tag{0x02}, []byte{0x06}),
+	)).MarshalBinary()
+	body := fmt.Sprintf(`{"transactionId":"0A0B","authenticateServerResponse":%q}`, base64.StdEncoding.EncodeToString(data))
+	request := httptest.NewRequest(http.MethodPost, "/gsma/rsp2/es9plus/authenticateClient", bytes.NewBufferString(body))
+	recorder := httptest.NewRecorder()
+	// the upstream holds CancelSession until release, ServeHTTP only returns when it does not wait for it
+	handler.ServeHTTP(recorder, request)
+	close(release)
+	handler.Wait()
+	if request := <-cancelled; request.TransactionId != "0A0B" {
+		t.Fatalf("cancelled transaction %q, want 0A0B", request.TransactionId)
+	}
+	if _, ok := handler.sessions().Load("0A0B"); ok {
+		t.Fatal("session kept after the cancellation")
+	}
+}
+
+func TestInitAuthenFailover(t *testing.T) {
+	ciPKNotSupported := Header{FunctionExecutionStatus: FunctionExecutionStatus{
+		Status:         Failed,
+		StatusCodeData: &StatusCodeData{SubjectCode: "8.8.2", ReasonCode: "3.1", Message: "ciPKNotSupported"},
+	}}
+	refused := Header{FunctionExecutionStatus: FunctionExecutionStatus{
+		Status:         Failed,
+		StatusCodeData: &StatusCodeData{SubjectCode: "8.1.1", ReasonCode: "3.8", Message: "eidMismatch"},
+	}}
+	cases := []struct {
+		name   string
+		header Header
+		hosts  int
+		want   int
+	}{
+		{"host error tries the next host", ciPKNotSupported, 2, 2},
+		{"host error stops after the maximum attempts", ciPKNotSupported, DefaultFailoverAttempts + 2, DefaultFailoverAttempts},
+		{"eUICC error is final", refused, 2, 1},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var mutex sync.Mutex
+			var attempts int
+			var hosts []RegistryHost
+			var client *http.Client
+			for range c.hosts {
+				mock := newMockSMDP(t, func(*InitAuthenRequest) any {
+					mutex.Lock()
+					attempts++
+					mutex.Unlock()
+					return &InitAuthenResponse{Header: c.header}
+				})
+				hosts = append(hosts, RegistryHost{Address: mock.Listener.Addr().String()})
+				client = mock.Client()
+			}
+			handler := &Handler{Client: client, Issuers: Registry{testProbeIssuer: hosts}}
+			body, _ := json.Marshal(&InitAuthenRequest{
+				Challenge: make([]byte, 16),
+				Address:   "smdp.example.com",
+				Info1:     testInfo1(testProbeIssuer),
+			})
+			request := httptest.NewRequest(http.MethodPost, "/gsma/rsp2/es9plus/initiateAuthentication", bytes.NewReader(body))
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, request)
+			var response GeneralResponse
+			if err := json.NewDecoder(recorder.Body).Decode(&response); err != nil {
+				t.Fatal(err)
+			}
+			if err := response.Header.Err(); err == nil {
+				t.Fatal("functionExecutionStatus = success, want the upstream error")
+			}
+			if attempts != c.want {
+				t.Fatalf("attempts = %d, want %d", attempts, c.want)
+			}
+		})
+	}
+}
+
+func TestHandlerSetCIBundle(t *testing.T) {
+	ci := newTestCI(t)
+	keyId := hex.EncodeToString(ci.certificate.subjectKeyId())
+	handler := &Handler{CIs: CIBundle{keyId: ci.certificate}}
+	if handler.currentCIs().lookup(ci.certificate.subjectKeyId()) == nil {
+		t.Fatal("configured CI bundle is not used")
+	}
+	handler.SetCIBundle(nil)
+	if cis := handler.currentCIs(); len(cis) != 0 {
+		t.Fatalf("reloaded bundle has %d CIs, want none", len(cis))
+	}
+}
@@ -0,0 +1,145 @@
+package dump
+
+import (
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultFailureThreshold = 3
+	DefaultCoolDown         = 5 * time.Minute
+)
+
+type HostHealth struct {
+	Host                string        `json:"host"`
+	Successes           uint64        `json:"successes"`
+	Failures            uint64        `json:"failures"`
+	ConsecutiveFailures int           `json:"consecutiveFailures"`
+	Latency             time.Duration `json:"latency"`
+	LastError           string        `json:"lastError,omitempty"`
+	LastFailure         time.Time     `json:"lastFailure,omitempty"`
+	OpenUntil           time.Time     `json:"openUntil,omitempty"`
+}
+
+type HealthTracker struct {
+	FailureThreshold int
+	CoolDown         time.Duration
+	mutex            sync.Mutex
+	hosts            map[string]*HostHealth
+}
+
+func NewHealthTracker() *HealthTracker {
+	return &HealthTracker{
+		FailureThreshold: DefaultFailureThreshold,
+		CoolDown:         DefaultCoolDown,
+		hosts:            make(map[string]*HostHealth),
+	}
+}
+
+func (t *HealthTracker) Success(host string, latency time.Duration) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	health := t.host(host)
+	health.Successes++
+	health.ConsecutiveFailures = 0
+	health.OpenUntil = time.Time{}
+	if health.Latency == 0 {
+		health.Latency = latency
+	} else {
+		health.Latency = (health.Latency*7 + latency) / 8
+	}
+}
+
+func (t *HealthTracker) Failure(host string, err error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	health := t.host(host)
+	health.Failures++
+	health.ConsecutiveFailures++
+	health.LastFailure = time.Now()
+	if err != nil {
+		health.LastError = err.Error()
+	}
+	if health.ConsecutiveFailures >= t.FailureThreshold {
+		health.OpenUntil = health.LastFailure.Add(t.CoolDown)
+	}
+}
+
+func (t *HealthTracker) Available(host string) bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.available(t.host(host))
+}
+
+// Order returns the hosts in attempt order: weighted random among the available hosts,
+// the hosts whose circuit is open are skipped unless no host is available.
+// The configured host weight scales the observed success rate.
+func (t *HealthTracker) Order(hosts []RegistryHost) []RegistryHost {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	var available, unavailable []RegistryHost
+	var weights []float64
+	for _, host := range hosts {
+		health := t.host(host.Endpoint())
+		if !t.available(health) {
+			unavailable = append(unavailable, host)
+			continue
+		}
+		available = append(available, host)
+		weights = append(weights, host.weight()*t.weight(health))
+	}
+	if len(available) == 0 {
+		return unavailable
+	}
+	ordered := make([]RegistryHost, 0, len(available))
+	for len(available) > 0 {
+		var total float64
+		for _, weight := range weights {
+			total += weight
+		}
+		index, point := 0, rand.Float64()*total
+		for ; index < len(available)-1; index++ {
+			if point -= weights[index]; point < 0 {
+				break
+			}
+		}
+		ordered = append(ordered, available[index])
+		available = slices.Delete(available, index, index+1)
+		weights = slices.Delete(weights, index, index+1)
+	}
+	return ordered
+}
+
+func (t *HealthTracker) Snapshot() []HostHealth {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	snapshot := make([]HostHealth, 0, len(t.hosts))
+	for _, health := range t.hosts {
+		snapshot = append(snapshot, *health)
+	}
+	slices.SortFunc(snapshot, func(a, b HostHealth) int {
+		return strings.Compare(a.Host, b.Host)
+	})
+	return snapshot
+}
+
+func (t *HealthTracker) host(host string) *HostHealth {
+	health, ok := t.hosts[host]
+	if !ok {
+		health = &HostHealth{Host: host}
+		t.hosts[host] = health
+	}
+	return health
+}
+
+func (t *HealthTracker) available(health *HostHealth) bool {
+	return health.OpenUntil.IsZero() || time.Now().After(health.OpenUntil)
+}
+
+// success rate with Laplace smoothing, so unknown hosts start at 0.5
+func (t *HealthTracker) weight(health *HostHealth) float64 {
+	return float64(health.Successes+1) / float64(health.Successes+health.Failures+2)
+}
@@ -0,0 +1,43 @@
+package dump
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestHealthTrackerCircuit(t *testing.T) {
+	tracker := NewHealthTracker()
+	for range tracker.FailureThreshold - 1 {
+		tracker.Failure("smdp.example.com", errors.New("unreachable"))
+	}
+	if !tracker.Available("smdp.example.com") {
+		t.Fatal("circuit opened before the failure threshold")
+	}
+	tracker.Failure("smdp.example.com", errors.New("unreachable"))
+	if tracker.Available("smdp.example.com") {
+		t.Fatal("circuit still closed at the failure threshold")
+	}
+	tracker.Success("smdp.example.com", 0)
+	if !tracker.Available("smdp.example.com") {
+		t.Fatal("circuit still open after a success")
+	}
+}
+
+func TestHealthTrackerOrderSkipsOpenHosts(t *testing.T) {
+	tracker := NewHealthTracker()
+	hosts := []RegistryHost{{Address: "a.example.com"}, {Address: "b.example.com"}}
+	for range tracker.FailureThreshold {
+		tracker.Failure("a.example.com", errors.New("unreachable"))
+	}
+	for range 16 {
+		if ordered := tracker.Order(hosts); len(ordered) != 1 || ordered[0].Address != "b.example.com" {
+			t.Fatalf("Order = %v, want only b.example.com", ordered)
+		}
+	}
+	for range tracker.FailureThreshold {
+		tracker.Failure("b.example.com", errors.New("unreachable"))
+	}
+	if ordered := tracker.Order(hosts); len(ordered) != 2 {
+		t.Fatalf("Order = %v, want both hosts once every circuit is open", ordered)
+	}
+}
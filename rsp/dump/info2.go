@@ -0,0 +1,562 @@
+package dump
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	. "github.com/euicc-go/bertlv"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// EUICCInfo2 ::= [34] SEQUENCE, decoded field by field.
+// Elements with an unknown tag, duplicated or invalid are kept in Raw,
+// MarshalBerTLV writes them back at their original position. A modelled element
+// the model does not reproduce byte for byte (e.g. a string padded with spaces)
+// is written back as received while its field is unchanged.
+type EUICCInfo2 struct {
+	ProfileVersion              Version         `json:"profileVersion,omitempty"`
+	SVN                         Version         `json:"svn,omitempty"`
+	FirmwareVersion             Version         `json:"euiccFirmwareVer,omitempty"`
+	ExtCardResource             ExtCardResource `json:"extCardResource,omitempty"`
+	UICCCapability              *Bits           `json:"uiccCapability,omitempty"`
+	TS102241Version             *Version        `json:"ts102241Version,omitempty"`
+	GlobalPlatformVersion       *Version        `json:"globalplatformVersion,omitempty"`
+	RSPCapability               *Bits           `json:"rspCapability,omitempty"`
+	IssuerVerification          []HexString     `json:"euiccCiPKIdListForVerification,omitempty"`
+	IssuerSigning               []HexString     `json:"euiccCiPKIdListForSigning,omitempty"`
+	Category                    string          `json:"euiccCategory,omitempty"`
+	ForbiddenProfilePolicyRules *Bits           `json:"forbiddenProfilePolicyRules,omitempty"`
+	ProtectionProfileVersion    *Version        `json:"ppVersion,omitempty"`
+	SASAccreditationNumber      string          `json:"sasAccreditationNumber,omitempty"`
+	CertificationDataObject     *CertData       `json:"certificationDataObject,omitempty"`
+	TreProperties               *Bits           `json:"treProperties,omitempty"`
+	TreProductReference         string          `json:"treProductReference,omitempty"`
+	ProfilePackageVersions      []Version       `json:"additionalEuiccProfilePackageVersions,omitempty"`
+	LPAMode                     string          `json:"lpaMode,omitempty"`
+	IssuerSigningV3             []HexString     `json:"euiccCiPKIdListForSigningV3,omitempty"`
+	AdditionalEUICCInfo         HexString       `json:"additionalEuiccInfo,omitempty"`
+	HighestSVN                  *Version        `json:"highestSvn,omitempty"`
+	IoTSpecificInfo             *TLV            `json:"iotSpecificInfo,omitempty"`
+	Raw                         []*TLV          `json:"rawElements,omitempty"`
+	layout                      []info2Element
+}
+
+// info2Element is the position of a received element, raw indexes Raw or is -1 for a modelled one,
+// original is the received element when the model encoded it as decoded instead
+type info2Element struct {
+	tag      Tag
+	raw      int
+	original *TLV
+	decoded  *TLV
+}
+
+type info2Field struct {
+	tag      Tag
+	name     string
+	required bool
+	decode   func(*EUICCInfo2, *TLV) error
+	encode   func(*EUICCInfo2, Tag) *TLV
+	// initial is the DEFAULT stored through fallback when the element is absent,
+	// a model built from scratch omits the element holding it
+	fallback func(*EUICCInfo2) *string
+	initial  string
+}
+
+var (
+	euiccCategories  = []string{"Other", "Basic eUICC", "Medium eUICC", "Contactless eUICC"}
+	lpaModes         = []string{"lpad", "lpae"}
+	uiccCapabilities = []string{
+		"Contactless Support", "USIM Support", "ISIM Support", "CSIM Support",
+		"DeviceInfo Extensibility Support",
+		"AkaMilenage", "AkaCave", "AkaTuak128", "AkaTuak256",
+		"RFU1", "RFU2",
+		"GBA Authentication USIM", "GBA Authentication ISIM", "MBMS Authentication USIM",
+		"EAP Client", "JavaCard", "MultOS",
+		"Multiple USIM Support", "Multiple ISIM Support", "Multiple CSIM Support",
+		"Ber TLV File Support", "DF Link Support",
+		"CAT TP", "GET IDENTITY", "profile-a-x25519", "profile-b-p256", "SUCICalculatorAPI",
+		"DNS Resolution", "SCP11ac", "SCP11c Authorization Mechanism", "S16 Mode", "eAKA", "IoT Minimal",
+	}
+	pprIds        = []string{"pprUpdateControl", "ppr1", "ppr2"}
+	treProperties = []string{"isDiscrete", "isIntegrated", "usesRemoteMemory"}
+)
+
+// euiccInfo2Fields in the order of the SGP.22 definition
+var euiccInfo2Fields = []info2Field{
+	versionField(Tag{0x81}, "profileVersion", func(e *EUICCInfo2) *Version { return &e.ProfileVersion }),
+	versionField(Tag{0x82}, "svn", func(e *EUICCInfo2) *Version { return &e.SVN }),
+	versionField(Tag{0x83}, "euiccFirmwareVer", func(e *EUICCInfo2) *Version { return &e.FirmwareVersion }),
+	{
+		tag: Tag{0x84}, name: "extCardResource", required: true,
+		decode: func(e *EUICCInfo2, tlv *TLV) error { return e.ExtCardResource.unmarshal(tlv.Value) },
+		encode: func(e *EUICCInfo2, tag Tag) *TLV { return NewValue(tag, e.ExtCardResource.marshal()) },
+	},
+	bitsField(Tag{0x85}, "uiccCapability", true, uiccCapabilities, func(e *EUICCInfo2) **Bits { return &e.UICCCapability }),
+	optionalVersionField(Tag{0x86}, "ts102241Version", func(e *EUICCInfo2) **Version { return &e.TS102241Version }),
+	optionalVersionField(Tag{0x87}, "globalplatformVersion", func(e *EUICCInfo2) **Version { return &e.GlobalPlatformVersion }),
+	bitsField(Tag{0x88}, "rspCapability", true, rspCapabilities, func(e *EUICCInfo2) **Bits { return &e.RSPCapability }),
+	keyIdsField(Tag{0xA9}, "euiccCiPKIdListForVerification", true, func(e *EUICCInfo2) *[]HexString { return &e.IssuerVerification }),
+	keyIdsField(Tag{0xAA}, "euiccCiPKIdListForSigning", true, func(e *EUICCInfo2) *[]HexString { return &e.IssuerSigning }),
+	withDefault(
+		enumField(Tag{0x8B}, "euiccCategory", euiccCategories, func(e *EUICCInfo2) *string { return &e.Category }),
+		"Other", func(e *EUICCInfo2) *string { return &e.Category },
+	),
+	bitsField(Tag{0x99}, "forbiddenProfilePolicyRules", false, pprIds, func(e *EUICCInfo2) **Bits { return &e.ForbiddenProfilePolicyRules }),
+	{
+		tag: Tag{0x04}, name: "ppVersion", required: true,
+		decode: func(e *EUICCInfo2, tlv *TLV) error {
+			version, err := decodeVersion(tlv)
+			if err == nil {
+				e.ProtectionProfileVersion = &version
+			}
+			return err
+		},
+		encode: func(e *EUICCInfo2, tag Tag) *TLV {
+			if e.ProtectionProfileVersion == nil {
+				return nil
+			}
+			return NewValue(tag, e.ProtectionProfileVersion[:])
+		},
+	},
+	stringField(Tag{0x0C}, "sasAcreditationNumber", true, func(e *EUICCInfo2) *string { return &e.SASAccreditationNumber }),
+	{
+		tag: Tag{0xAC}, name: "certificationDataObject",
+		decode: func(e *EUICCInfo2, tlv *TLV) error {
+			label, url := tlv.First(Tag{0x80}), tlv.First(Tag{0x81})
+			if label == nil || url == nil {
+				return errors.New("missing platformLabel or discoveryBaseURL")
+			}
+			if !utf8.Valid(label.Value) || !utf8.Valid(url.Value) {
+				return errors.New("invalid UTF-8 string")
+			}
+			e.CertificationDataObject = &CertData{
+				PlatformLabel:    strings.TrimSpace(string(label.Value)),
+				DiscoveryBaseURL: strings.TrimSpace(string(url.Value)),
+			}
+			return nil
+		},
+		encode: func(e *EUICCInfo2, tag Tag) *TLV {
+			if e.CertificationDataObject == nil {
+				return nil
+			}
+			return NewChildren(tag,
+				NewValue(Tag{0x80}, []byte(e.CertificationDataObject.PlatformLabel)),
+				NewValue(Tag{0x81}, []byte(e.CertificationDataObject.DiscoveryBaseURL)),
+			)
+		},
+	},
+	bitsField(Tag{0x8D}, "treProperties", false, treProperties, func(e *EUICCInfo2) **Bits { return &e.TreProperties }),
+	stringField(Tag{0x8E}, "treProductReference", false, func(e *EUICCInfo2) *string { return &e.TreProductReference }),
+	{
+		tag: Tag{0xAF}, name: "additionalEuiccProfilePackageVersions",
+		decode: func(e *EUICCInfo2, tlv *TLV) error {
+			versions := make([]Version, len(tlv.Children))
+			for index, child := range tlv.Children {
+				if !bytes.Equal(child.Tag, Tag{0x04}) {
+					return fmt.Errorf("unexpected element %X", []byte(child.Tag))
+				}
+				version, err := decodeVersion(child)
+				if err != nil {
+					return err
+				}
+				versions[index] = version
+			}
+			e.ProfilePackageVersions = versions
+			return nil
+		},
+		encode: func(e *EUICCInfo2, tag Tag) *TLV {
+			if e.ProfilePackageVersions == nil {
+				return nil
+			}
+			versions := NewChildren(tag)
+			for _, version := range e.ProfilePackageVersions {
+				versions.Children = append(versions.Children, NewValue(Tag{0x04}, version[:]))
+			}
+			return versions
+		},
+	},
+	enumField(Tag{0x90}, "lpaMode", lpaModes, func(e *EUICCInfo2) *string { return &e.LPAMode }),
+	keyIdsField(Tag{0xB1}, "euiccCiPKIdListForSigningV3", false, func(e *EUICCInfo2) *[]HexString { return &e.IssuerSigningV3 }),
+	{
+		tag: Tag{0x92}, name: "additionalEuiccInfo",
+		decode: func(e *EUICCInfo2, tlv *TLV) error {
+			e.AdditionalEUICCInfo = slices.Clone(tlv.Value)
+			if e.AdditionalEUICCInfo == nil {
+				e.AdditionalEUICCInfo = HexString{}
+			}
+			return nil
+		},
+		encode: func(e *EUICCInfo2, tag Tag) *TLV {
+			if e.AdditionalEUICCInfo == nil {
+				return nil
+			}
+			return NewValue(tag, e.AdditionalEUICCInfo)
+		},
+	},
+	optionalVersionField(Tag{0x93}, "highestSvn", func(e *EUICCInfo2) **Version { return &e.HighestSVN }),
+	{
+		tag: Tag{0xB4}, name: "iotSpecificInfo",
+		decode: func(e *EUICCInfo2, tlv *TLV) error {
+			e.IoTSpecificInfo = tlv
+			return nil
+		},
+		encode: func(e *EUICCInfo2, _ Tag) *TLV { return e.IoTSpecificInfo },
+	},
+}
+
+func (e *EUICCInfo2) UnmarshalBerTLV(tlv *TLV) error {
+	var info EUICCInfo2
+	var problems DecodeErrors
+	if tlv == nil {
+		return DecodeErrors{{Field: "euiccInfo2", Tag: Tag{0xBF, 0x22}, Reason: "missing"}}
+	}
+	decoded := make(map[string]bool)
+	for _, child := range tlv.Children {
+		field := lookupInfo2Field(child.Tag)
+		if field == nil || decoded[string(child.Tag)] {
+			info.keep(child)
+			continue
+		}
+		decoded[string(child.Tag)] = true
+		if err := field.decode(&info, child); err != nil {
+			problems = append(problems, &DecodeError{Field: "euiccInfo2." + field.name, Tag: child.Tag, Reason: err.Error()})
+			info.keep(child)
+			continue
+		}
+		element := info2Element{tag: child.Tag, raw: -1}
+		if encoded := field.encode(&info, child.Tag); !equalTLV(encoded, child) {
+			element.original, element.decoded = child, encoded
+		}
+		info.layout = append(info.layout, element)
+	}
+	for _, field := range euiccInfo2Fields {
+		switch {
+		case decoded[string(field.tag)]:
+		case field.required:
+			problems = append(problems, &DecodeError{Field: "euiccInfo2." + field.name, Tag: field.tag, Reason: "missing"})
+		case field.fallback != nil:
+			*field.fallback(&info) = field.initial
+		}
+	}
+	*e = info
+	if len(problems) > 0 {
+		return problems
+	}
+	return nil
+}
+
+// MarshalBerTLV encodes the elements in the order they were received,
+// a model built from scratch is encoded in the SGP.22 order followed by Raw.
+func (e *EUICCInfo2) MarshalBerTLV() (*TLV, error) {
+	tlv := NewChildren(Tag{0xBF, 0x22})
+	layout := e.layout
+	if layout == nil {
+		for _, field := range euiccInfo2Fields {
+			if field.fallback != nil && *field.fallback(e) == field.initial {
+				continue
+			}
+			layout = append(layout, info2Element{tag: field.tag, raw: -1})
+		}
+		for index, raw := range e.Raw {
+			layout = append(layout, info2Element{tag: raw.Tag, raw: index})
+		}
+	}
+	for _, element := range layout {
+		if element.raw >= 0 {
+			if element.raw >= len(e.Raw) {
+				return nil, fmt.Errorf("euiccInfo2: raw element %X is missing", []byte(element.tag))
+			}
+			tlv.Children = append(tlv.Children, e.Raw[element.raw])
+			continue
+		}
+		child := lookupInfo2Field(element.tag).encode(e, element.tag)
+		if element.original != nil && equalTLV(child, element.decoded) {
+			child = element.original
+		}
+		if child != nil {
+			tlv.Children = append(tlv.Children, child)
+		}
+	}
+	return tlv, nil
+}
+
+func (e *EUICCInfo2) keep(tlv *TLV) {
+	e.layout = append(e.layout, info2Element{tag: tlv.Tag, raw: len(e.Raw)})
+	e.Raw = append(e.Raw, tlv)
+}
+
+func lookupInfo2Field(tag Tag) *info2Field {
+	for index := range euiccInfo2Fields {
+		if bytes.Equal(euiccInfo2Fields[index].tag, tag) {
+			return &euiccInfo2Fields[index]
+		}
+	}
+	return nil
+}
+
+func versionField(tag Tag, name string, field func(*EUICCInfo2) *Version) info2Field {
+	return info2Field{
+		tag: tag, name: name, required: true,
+		decode: func(e *EUICCInfo2, tlv *TLV) (err error) {
+			*field(e), err = decodeVersion(tlv)
+			return
+		},
+		encode: func(e *EUICCInfo2, tag Tag) *TLV { return NewValue(tag, field(e)[:]) },
+	}
+}
+
+func optionalVersionField(tag Tag, name string, field func(*EUICCInfo2) **Version) info2Field {
+	return info2Field{
+		tag: tag, name: name,
+		decode: func(e *EUICCInfo2, tlv *TLV) error {
+			version, err := decodeVersion(tlv)
+			if err == nil {
+				*field(e) = &version
+			}
+			return err
+		},
+		encode: func(e *EUICCInfo2, tag Tag) *TLV {
+			if *field(e) == nil {
+				return nil
+			}
+			return NewValue(tag, (*field(e))[:])
+		},
+	}
+}
+
+func bitsField(tag Tag, name string, required bool, names []string, field func(*EUICCInfo2) **Bits) info2Field {
+	return info2Field{
+		tag: tag, name: name, required: required,
+		decode: func(e *EUICCInfo2, tlv *TLV) (err error) {
+			*field(e), err = NewBits(tlv.Value, names)
+			return
+		},
+		encode: func(e *EUICCInfo2, tag Tag) *TLV {
+			if *field(e) == nil {
+				return nil
+			}
+			return NewValue(tag, (*field(e)).Value())
+		},
+	}
+}
+
+func keyIdsField(tag Tag, name string, required bool, field func(*EUICCInfo2) *[]HexString) info2Field {
+	return info2Field{
+		tag: tag, name: name, required: required,
+		decode: func(e *EUICCInfo2, tlv *TLV) error {
+			keyIds := make([]HexString, 0, len(tlv.Children))
+			for _, child := range tlv.Children {
+				if !bytes.Equal(child.Tag, Tag{0x04}) {
+					return fmt.Errorf("unexpected element %X", []byte(child.Tag))
+				}
+				keyIds = append(keyIds, child.Value)
+			}
+			*field(e) = keyIds
+			return nil
+		},
+		encode: func(e *EUICCInfo2, tag Tag) *TLV {
+			if *field(e) == nil {
+				return nil
+			}
+			list := NewChildren(tag)
+			for _, keyId := range *field(e) {
+				list.Children = append(list.Children, NewValue(Tag{0x04}, keyId))
+			}
+			return list
+		},
+	}
+}
+
+// enumField is an INTEGER with named values, an unnamed value is kept as "unknown (n)"
+func enumField(tag Tag, name string, names []string, field func(*EUICCInfo2) *string) info2Field {
+	return info2Field{
+		tag: tag, name: name,
+		decode: func(e *EUICCInfo2, tlv *TLV) (err error) {
+			*field(e), err = decodeEnum(tlv.Value, names)
+			return
+		},
+		encode: func(e *EUICCInfo2, tag Tag) *TLV {
+			if index := slices.Index(names, *field(e)); index != -1 {
+				return NewValue(tag, []byte{byte(index)})
+			}
+			return nil
+		},
+	}
+}
+
+// decodeEnum names an INTEGER value, values without a name are "unknown (n)"
+func decodeEnum(value []byte, names []string) (string, error) {
+	if len(value) == 0 || len(value) > 8 {
+		return "", fmt.Errorf("invalid integer length %d", len(value))
+	}
+	if len(value) > 1 || int(value[0]) >= len(names) || names[value[0]] == "" {
+		return fmt.Sprintf("unknown (%d)", variant(value)), nil
+	}
+	return names[value[0]], nil
+}
+
+func withDefault(field info2Field, initial string, fallback func(*EUICCInfo2) *string) info2Field {
+	field.initial, field.fallback = initial, fallback
+	return field
+}
+
+// stringField is an UTF8String without its surrounding spaces
+func stringField(tag Tag, name string, required bool, field func(*EUICCInfo2) *string) info2Field {
+	return info2Field{
+		tag: tag, name: name, required: required,
+		decode: func(e *EUICCInfo2, tlv *TLV) error {
+			if !utf8.Valid(tlv.Value) {
+				return errors.New("invalid UTF-8 string")
+			}
+			*field(e) = strings.TrimSpace(string(tlv.Value))
+			return nil
+		},
+		encode: func(e *EUICCInfo2, tag Tag) *TLV {
+			if *field(e) == "" && !required {
+				return nil
+			}
+			return NewValue(tag, []byte(*field(e)))
+		},
+	}
+}
+
+func decodeVersion(tlv *TLV) (version Version, err error) {
+	if len(tlv.Value) != len(version) {
+		return version, fmt.Errorf("invalid version length %d", len(tlv.Value))
+	}
+	return Version(tlv.Value), nil
+}
+
+// ExtCardResource ::= OCTET STRING holding the TLVs of ETSI TS 102 226
+func (r *ExtCardResource) unmarshal(value []byte) error {
+	var resource ExtCardResource
+	reader := bytes.NewReader(value)
+	for reader.Len() > 0 {
+		element := new(TLV)
+		if _, err := element.ReadFrom(reader); err != nil {
+			return err
+		}
+		if len(element.Value) > 8 {
+			return fmt.Errorf("integer %X is too large", []byte(element.Tag))
+		}
+		switch element.Tag[0] {
+		case 0x81:
+			resource.InstallApps = variant(element.Value)
+		case 0x82:
+			resource.FreeNVRAM = variant(element.Value)
+		case 0x83:
+			resource.FreeRAM = variant(element.Value)
+		}
+	}
+	*r = resource
+	return nil
+}
+
+func (r *ExtCardResource) marshal() []byte {
+	var buf bytes.Buffer
+	for index, value := range []uint64{r.InstallApps, r.FreeNVRAM, r.FreeRAM} {
+		_, _ = NewValue(Tag{0x81 + byte(index)}, encodeInteger(value)).WriteTo(&buf)
+	}
+	return buf.Bytes()
+}
+
+// encodeInteger is the minimal DER encoding of a non-negative INTEGER
+func encodeInteger(value uint64) []byte {
+	encoded := []byte{byte(value)}
+	for value >>= 8; value > 0; value >>= 8 {
+		encoded = append([]byte{byte(value)}, encoded...)
+	}
+	if encoded[0]&0x80 != 0 {
+		encoded = append([]byte{0}, encoded...)
+	}
+	return encoded
+}
+
+func equalTLV(a, b *TLV) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	encodedA, errA := a.MarshalBinary()
+	encodedB, errB := b.MarshalBinary()
+	return errA == nil && errB == nil && bytes.Equal(encodedA, encodedB)
+}
+
+// Bits is a BIT STRING with named bits, set bits without a name are reported as "bit<N>"
+type Bits struct {
+	Length int
+	Bytes  []byte
+	names  []string
+}
+
+func NewBits(value []byte, names []string) (*Bits, error) {
+	switch {
+	case len(value) == 0:
+		return nil, errors.New("empty bit string")
+	case value[0] > 7 || (len(value) == 1 && value[0] != 0):
+		return nil, fmt.Errorf("invalid unused bits %d", value[0])
+	}
+	return &Bits{Length: (len(value)-1)*8 - int(value[0]), Bytes: slices.Clone(value[1:]), names: names}, nil
+}
+
+func (b *Bits) At(index int) bool {
+	if index < 0 || index >= b.Length {
+		return false
+	}
+	return b.Bytes[index/8]&(0x80>>(index%8)) != 0
+}
+
+func (b *Bits) Names() (names []string) {
+	names = make([]string, 0)
+	for index := 0; index < b.Length; index++ {
+		switch {
+		case !b.At(index):
+			continue
+		case index < len(b.names):
+			names = append(names, b.names[index])
+		default:
+			names = append(names, fmt.Sprintf("bit%d", index))
+		}
+	}
+	return
+}
+
+func (b *Bits) Has(name string) bool {
+	return slices.Contains(b.Names(), name)
+}
+
+// Value is the BIT STRING content: the unused bit count followed by the bytes
+func (b *Bits) Value() []byte {
+	return append([]byte{byte(len(b.Bytes)*8 - b.Length)}, b.Bytes...)
+}
+
+func (b *Bits) MarshalJSON() ([]byte, error) {
+	return json.Marshal(b.Names())
+}
+
+type DecodeError struct {
+	Field  string
+	Tag    Tag
+	Reason string
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("%s (%X): %s", e.Field, []byte(e.Tag), e.Reason)
+}
+
+func (e *DecodeError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(e.Error())
+}
+
+// DecodeErrors lists every problem found while decoding, the decoded object is still usable
+type DecodeErrors []*DecodeError
+
+func (e DecodeErrors) Error() string {
+	messages := make([]string, len(e))
+	for index, err := range e {
+		messages[index] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
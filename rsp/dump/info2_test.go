@@ -0,0 +1,121 @@
+package dump
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	. "github.com/euicc-go/bertlv"
+	"testing"
+)
+
+// synthetic EUICCInfo2 responses, no device answer is bundled with the repository
+var euiccInfo2Fixtures = map[string]string{
+	// SGP.22 v2.2: no euiccCategory, two CIs
+	"v2.2": "bf2281a48103020300820302020183030d0100840c810100820301f23c83023a9885030367f68603090200870302030088020770a92c041481370f5125d0b1d408d4c3b232e6d25e795bebfb0414f54172bdf98a95d65cbeb88a38a1c11d800a85c3aa2c041481370f5125d0b1d408d4c3b232e6d25e795bebfb0414f54172bdf98a95d65cbeb88a38a1c11d800a85c39902078004030000010c0d47492d42412d55502d30343139",
+	// SGP.22 v3: every optional element, a padded sasAcreditationNumber and an unknown 9F7F
+	"v3": "bf2281eb810302030082030301008303020000840c810100820301f23c83023a9885040167f6de86031101008703020301880303faf8a916041481370f5125d0b1d408d4c3b232e6d25e795bebfbaa16041481370f5125d0b1d408d4c3b232e6d25e795bebfb8b01029902050004030100000c10202058582d59592d55502d3132333420ac2a80104578616d706c6520506c6174666f726d811668747470733a2f2f64732e6578616d706c652e636f6d8d0205408e085452452d30303031af0a04030203000403030300900100b116041481370f5125d0b1d408d4c3b232e6d25e795bebfb93030301009f7f020102",
+	// euiccCategory encoded with its DEFAULT value, then duplicated
+	"default": "bf226f810302010082030201008303010000840c810100820301f23c83023a988502056088020770a916041481370f5125d0b1d408d4c3b232e6d25e795bebfbaa16041481370f5125d0b1d408d4c3b232e6d25e795bebfb8b010004030000010c0d47492d42412d55502d303431398b0101",
+}
+
+func TestEUICCInfo2RoundTrip(t *testing.T) {
+	for name, fixture := range euiccInfo2Fixtures {
+		t.Run(name, func(t *testing.T) {
+			data, _ := hex.DecodeString(fixture)
+			tlv := new(TLV)
+			if err := tlv.UnmarshalBinary(data); err != nil {
+				t.Fatal(err)
+			}
+			var info EUICCInfo2
+			if err := info.UnmarshalBerTLV(tlv); err != nil {
+				t.Fatal(err)
+			}
+			encoded, err := info.MarshalBerTLV()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, _ := encoded.MarshalBinary(); !bytes.Equal(got, data) {
+				t.Fatalf("MarshalBerTLV = %X, want %X", got, data)
+			}
+		})
+	}
+}
+
+func TestEUICCInfo2Decode(t *testing.T) {
+	decode := func(name string) *EUICCInfo2 {
+		data, _ := hex.DecodeString(euiccInfo2Fixtures[name])
+		tlv := new(TLV)
+		if err := tlv.UnmarshalBinary(data); err != nil {
+			t.Fatal(err)
+		}
+		info := new(EUICCInfo2)
+		if err := info.UnmarshalBerTLV(tlv); err != nil {
+			t.Fatal(err)
+		}
+		return info
+	}
+	if info := decode("v2.2"); info.Category != "Other" {
+		t.Fatalf("absent euiccCategory = %q, want the DEFAULT Other", info.Category)
+	}
+	info := decode("v3")
+	if info.SASAccreditationNumber != "XX-YY-UP-1234" {
+		t.Fatalf("sasAcreditationNumber = %q, want it trimmed", info.SASAccreditationNumber)
+	}
+	if info.CertificationDataObject == nil || info.CertificationDataObject.PlatformLabel != "Example Platform" {
+		t.Fatalf("certificationDataObject = %+v", info.CertificationDataObject)
+	}
+	if info.Category != "Medium eUICC" || info.LPAMode != "lpad" || len(info.Raw) != 1 {
+		t.Fatalf("euiccCategory = %q, lpaMode = %q, %d raw elements", info.Category, info.LPAMode, len(info.Raw))
+	}
+	// only the unknown 9F7F is raw, the padded sasAcreditationNumber is modelled
+	if !bytes.Equal(info.Raw[0].Tag, Tag{0x9F, 0x7F}) {
+		t.Fatalf("raw element %X, want 9F7F", []byte(info.Raw[0].Tag))
+	}
+	if data, _ := json.Marshal(info); bytes.Count(data, []byte("XX-YY-UP-1234")) != 1 {
+		t.Fatalf("sasAccreditationNumber is not reported once: %s", data)
+	}
+}
+
+func TestEUICCInfo2MarshalChangedPaddedString(t *testing.T) {
+	data, _ := hex.DecodeString(euiccInfo2Fixtures["v3"])
+	tlv := new(TLV)
+	if err := tlv.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	var info EUICCInfo2
+	if err := info.UnmarshalBerTLV(tlv); err != nil {
+		t.Fatal(err)
+	}
+	info.SASAccreditationNumber = "GI-BA-UP-0419"
+	encoded, err := info.MarshalBerTLV()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := encoded.First(Tag{0x0C}); got == nil || string(got.Value) != "GI-BA-UP-0419" {
+		t.Fatalf("sasAcreditationNumber = %v, want the changed value", got)
+	}
+}
+
+func TestEUICCInfo2MarshalOmitsDefault(t *testing.T) {
+	info := &EUICCInfo2{Category: "Other", SASAccreditationNumber: "GI-BA-UP-0419"}
+	tlv, err := info.MarshalBerTLV()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tlv.First(Tag{0x8B}) != nil {
+		t.Fatal("euiccCategory holding its DEFAULT is encoded")
+	}
+	info.Category = "Basic eUICC"
+	if tlv, _ = info.MarshalBerTLV(); tlv.First(Tag{0x8B}) == nil {
+		t.Fatal("euiccCategory is missing")
+	}
+}
+
+func TestEUICCInfo2MissingElements(t *testing.T) {
+	var info EUICCInfo2
+	var problems DecodeErrors
+	if err := info.UnmarshalBerTLV(NewChildren(Tag{0xBF, 0x22})); !errors.As(err, &problems) || len(problems) == 0 {
+		t.Fatalf("err = %v, want the missing mandatory elements", err)
+	}
+}
@@ -3,7 +3,6 @@ package dump
 import (
 	"bytes"
 	"crypto/sha1"
-	"crypto/x509"
 	_ "embed"
 	"encoding/hex"
 	"encoding/json"
@@ -11,10 +10,31 @@ import (
 	"gopkg.in/mail.v2"
 	"html/template"
 	"io"
+	"sync/atomic"
 )
 
 //go:embed mail-tpl.gohtml
-var mailTemplate string
+var defaultMailTemplate string
+
+var mailTemplate atomic.Pointer[template.Template]
+
+func init() {
+	mailTemplate.Store(template.Must(ParseMailTemplate(defaultMailTemplate)))
+}
+
+func ParseMailTemplate(text string) (*template.Template, error) {
+	return template.New("mail").Funcs(template.FuncMap{
+		"ciName":  CIName,
+		"ciLabel": CILabel,
+	}).Parse(text)
+}
+
+func SetMailTemplate(tpl *template.Template) {
+	if tpl == nil {
+		tpl = template.Must(ParseMailTemplate(defaultMailTemplate))
+	}
+	mailTemplate.Store(tpl)
+}
 
 func NewMailMessage(report *Report, issuerDomain string) *mail.Message {
 	message := mail.NewMessage()
@@ -23,50 +43,82 @@ func NewMailMessage(report *Report, issuerDomain string) *mail.Message {
 			"Content-Type": {"text/plain"},
 		}))
 	}
-	var eid, issuer string
+	if report.EUICCInfo1 != nil {
+		info1, _ := json.MarshalIndent(report.EUICCInfo1, "", "  ")
+		message.AttachReader("EUICCInfo1.json", bytes.NewReader(info1), mail.SetHeader(map[string][]string{
+			"Content-Type": {"text/plain"},
+		}))
+	}
+	if report.DeviceInfo != nil {
+		deviceInfo, _ := json.MarshalIndent(report.DeviceInfo, "", "  ")
+		message.AttachReader("DeviceInfo.json", bytes.NewReader(deviceInfo), mail.SetHeader(map[string][]string{
+			"Content-Type": {"text/plain"},
+		}))
+	}
+	if report.Verification != nil {
+		verification, _ := json.MarshalIndent(report.Verification, "", "  ")
+		message.AttachReader("Verification.json", bytes.NewReader(verification), mail.SetHeader(map[string][]string{
+			"Content-Type": {"text/plain"},
+		}))
+	}
+	if report.SAS != nil {
+		sas, _ := json.MarshalIndent(report.SAS, "", "  ")
+		message.AttachReader("SASAccreditation.json", bytes.NewReader(sas), mail.SetHeader(map[string][]string{
+			"Content-Type": {"text/plain"},
+		}))
+	}
+	if report.EID != nil {
+		eid, _ := json.MarshalIndent(report.EID, "", "  ")
+		message.AttachReader("EID.json", bytes.NewReader(eid), mail.SetHeader(map[string][]string{
+			"Content-Type": {"text/plain"},
+		}))
+	}
+	var issuer, issuerName string
 	if data, _ := report.EUICCCertificate.MarshalBinary(); data != nil {
-		opensslParsed := parseCertificate(data)
+		rendered := parseCertificate(data)
 		filename := fmt.Sprintf("EUICC-%02x.pem", sha1.Sum(data))
-		if parsed, _ := x509.ParseCertificate(data); parsed != nil {
-			eid = parsed.Subject.SerialNumber
-			filename = fmt.Sprintf("EUICC-%s-%02x.pem", eid[0:8], parsed.AuthorityKeyId[0:3])
+		if parsed, _ := parseRawCertificate(data); parsed != nil && report.EID != nil {
+			if aki := parsed.authorityKeyId(); len(aki) >= 3 {
+				filename = fmt.Sprintf("EUICC-%s-%02x.pem", report.EID.EIN, aki[0:3])
+			}
 		}
-		message.AttachReader(filename, bytes.NewReader(opensslParsed), mail.SetHeader(map[string][]string{
+		message.AttachReader(filename, bytes.NewReader(rendered), mail.SetHeader(map[string][]string{
 			"Content-Type": {"text/plain"},
 		}))
 	}
 	if data, _ := report.EUMCertificate.MarshalBinary(); data != nil {
-		opensslParsed := parseCertificate(data)
+		rendered := parseCertificate(data)
 		filename := fmt.Sprintf("EUM-%02x.pem", sha1.Sum(data))
-		if parsed, _ := x509.ParseCertificate(data); parsed != nil {
-			issuer = hex.EncodeToString(parsed.AuthorityKeyId)
-			filename = fmt.Sprintf("EUM-%s-%02x.pem", issuer[0:6], parsed.SubjectKeyId[0:3])
+		if parsed, _ := parseRawCertificate(data); parsed != nil {
+			aki := parsed.authorityKeyId()
+			issuer = hex.EncodeToString(aki)
+			issuerName = CIName(aki)
+			if ski := parsed.subjectKeyId(); len(aki) >= 3 && len(ski) >= 3 {
+				filename = fmt.Sprintf("EUM-%s-%02x.pem", issuer[0:6], ski[0:3])
+			}
 		}
-		message.AttachReader(filename, bytes.NewReader(opensslParsed), mail.SetHeader(map[string][]string{
+		message.AttachReader(filename, bytes.NewReader(rendered), mail.SetHeader(map[string][]string{
 			"Content-Type": {"text/plain"},
 		}))
 	}
 	subject := "RSP Dump Report"
-	if len(eid) == 32 && len(issuer) == 40 {
-		subject = fmt.Sprintf("%s (%s)", eid[0:16], issuer[0:6])
+	if report.EID != nil && len(issuer) == 40 {
+		subject = fmt.Sprintf("%s (%s)", report.EID.Value[0:16], issuer[0:6])
 	}
 	message.SetHeader("Subject", subject)
 	message.SetBodyWriter("text/html", func(w io.Writer) error {
-		tpl, err := template.New(eid).Parse(mailTemplate)
-		if err != nil {
-			return err
-		}
+		tpl := mailTemplate.Load()
 		data := new(struct {
 			Subject    string
-			EID        string
 			UsedIssuer string
+			IssuerName string
 			IssuerHost string
 			FreeNVRAM  float64
 			*Report
 		})
 		data.Subject = subject
-		data.EID = eid
 		data.UsedIssuer = issuer
+		data.IssuerName = issuerName
 		data.IssuerHost = issuerDomain
 		data.FreeNVRAM = float64(report.EUICCInfo2.ExtCardResource.FreeNVRAM) / 1024
 		data.Report = report
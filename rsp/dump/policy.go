@@ -0,0 +1,112 @@
+package dump
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/hex"
+	. "github.com/CursedHardware/go-rsp-dump/rsp/types"
+	. "github.com/euicc-go/bertlv"
+	"slices"
+	"strings"
+)
+
+// Values of the issuer group of HostPattern restricting the best match to one class of CI
+const (
+	IssuerClassProduction = "production"
+	IssuerClassTest       = "test"
+)
+
+// IssuerPolicy decides which issuer is used when the hostname does not name one.
+// The eUICC issuers are ranked by their index in Priority (key id prefixes or CI aliases,
+// unlisted issuers last), then production before test CIs if PreferProduction is set,
+// and finally by their order in euiccCiPKIdListForSigning and euiccCiPKIdListForSigningV3.
+type IssuerPolicy struct {
+	Priority         []string
+	PreferProduction bool
+}
+
+func (p *IssuerPolicy) rank(issuers [][]byte) {
+	slices.SortStableFunc(issuers, func(a, b []byte) int {
+		if order := cmp.Compare(p.priority(a), p.priority(b)); order != 0 || !p.PreferProduction {
+			return order
+		}
+		return cmp.Compare(testRank(a), testRank(b))
+	})
+}
+
+func testRank(keyId []byte) int {
+	if issuerClass(keyId) == IssuerClassTest {
+		return 1
+	}
+	return 0
+}
+
+func (p *IssuerPolicy) priority(keyId []byte) int {
+	encoded := hex.EncodeToString(keyId)
+	for index, value := range p.Priority {
+		if ci := LookupCIAlias(value); ci != nil && bytes.Equal(ci.KeyId, keyId) {
+			return index
+		}
+		if strings.HasPrefix(encoded, strings.ToLower(value)) {
+			return index
+		}
+	}
+	return len(p.Priority)
+}
+
+// issuerClass is IssuerClassTest for the test CIs of the catalog, IssuerClassProduction otherwise
+func issuerClass(keyId []byte) string {
+	if ci := LookupCI(keyId); ci != nil && ci.Test {
+		return IssuerClassTest
+	}
+	return IssuerClassProduction
+}
+
+func (rt *routing) findBestMatchHost(r *InitAuthenRequest, class string) (issuer []byte, hosts []RegistryHost, err error) {
+	var issuers [][]byte
+	for _, list := range []*TLV{r.Info1.First(Tag{0xAA}), r.Info1.First(Tag{0xB1})} {
+		if list == nil {
+			continue
+		}
+		for _, child := range list.Children {
+			if class != "" && issuerClass(child.Value) != class {
+				continue
+			}
+			if !slices.ContainsFunc(issuers, func(keyId []byte) bool { return bytes.Equal(keyId, child.Value) }) {
+				issuers = append(issuers, child.Value)
+			}
+		}
+	}
+	rt.policy.rank(issuers)
+	for _, keyId := range issuers {
+		if candidates := rt.candidates(hex.EncodeToString(keyId), r.Info1); len(candidates) > 0 {
+			return keyId, candidates, nil
+		}
+	}
+	return nil, nil, errNotFound
+}
+
+// findSpecificHost resolves a key id prefix: an exact key id wins,
+// otherwise the prefix must match a single issuer of the registry.
+func (rt *routing) findSpecificHost(r *InitAuthenRequest, prefix string) (issuer []byte, hosts []RegistryHost, err error) {
+	var matched []string
+	for keyId := range rt.issuers {
+		if strings.HasPrefix(keyId, prefix) {
+			matched = append(matched, keyId)
+		}
+	}
+	slices.Sort(matched)
+	switch {
+	case len(matched) == 0:
+		return nil, nil, errNotFound
+	case slices.Contains(matched, prefix):
+		matched = []string{prefix}
+	case len(matched) > 1:
+		return nil, nil, newError(errNotFound.SubjectCode, errNotFound.ReasonCode, "issuer %q is ambiguous, matches: %s", prefix, strings.Join(matched, ", "))
+	}
+	if hosts = rt.candidates(matched[0], r.Info1); len(hosts) == 0 {
+		return nil, nil, errNotFound
+	}
+	issuer, _ = hex.DecodeString(matched[0])
+	return issuer, hosts, nil
+}
@@ -0,0 +1,83 @@
+package dump
+
+import (
+	"encoding/hex"
+	. "github.com/CursedHardware/go-rsp-dump/rsp/types"
+	. "github.com/euicc-go/bertlv"
+	"strings"
+	"testing"
+)
+
+const (
+	testKeyIdG1      = "81370f5125d0b1d408d4c3b232e6d25e795bebfb"
+	testKeyIdTest    = "f54172bdf98a95d65cbeb88a38a1c11d800a85c3"
+	testKeyIdTestBRP = "c0bc70ba36929d43b467ff57570530e57ab8fcd8"
+)
+
+func testInfo1(keyIds ...string) *TLV {
+	verification := NewChildren(Tag{0xA9})
+	signing := NewChildren(Tag{0xAA})
+	for _, keyId := range keyIds {
+		value, _ := hex.DecodeString(keyId)
+		verification.Children = append(verification.Children, NewValue(Tag{0x04}, value))
+		signing.Children = append(signing.Children, NewValue(Tag{0x04}, value))
+	}
+	return NewChildren(Tag{0xBF, 0x20}, NewValue(Tag{0x82}, []byte{0x02, 0x02, 0x00}), verification, signing)
+}
+
+func TestIssuerPolicy(t *testing.T) {
+	issuers := Registry{
+		testKeyIdG1:      {{Address: "g1.example.com"}},
+		testKeyIdTest:    {{Address: "test.example.com"}},
+		testKeyIdTestBRP: {{Address: "test-brp.example.com"}},
+	}
+	cases := []struct {
+		name    string
+		policy  IssuerPolicy
+		class   string
+		signing []string
+		want    string
+	}{
+		{"signing order", IssuerPolicy{}, "", []string{testKeyIdTest, testKeyIdG1}, testKeyIdTest},
+		{"prefer production", IssuerPolicy{PreferProduction: true}, "", []string{testKeyIdTest, testKeyIdG1}, testKeyIdG1},
+		{"priority by alias", IssuerPolicy{Priority: []string{"gsma-test-brp"}}, "", []string{testKeyIdG1, testKeyIdTestBRP}, testKeyIdTestBRP},
+		{"priority by prefix", IssuerPolicy{Priority: []string{"C0BC"}}, "", []string{testKeyIdG1, testKeyIdTestBRP}, testKeyIdTestBRP},
+		{"priority before production", IssuerPolicy{Priority: []string{"gsma-test"}, PreferProduction: true}, "", []string{testKeyIdG1, testKeyIdTest}, testKeyIdTest},
+		{"priority order", IssuerPolicy{Priority: []string{"gsma-test-brp", "gsma-g1"}}, "", []string{testKeyIdG1, testKeyIdTestBRP}, testKeyIdTestBRP},
+		{"test class", IssuerPolicy{PreferProduction: true}, IssuerClassTest, []string{testKeyIdG1, testKeyIdTest}, testKeyIdTest},
+		{"production class", IssuerPolicy{}, IssuerClassProduction, []string{testKeyIdTest, testKeyIdG1}, testKeyIdG1},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			rt := &routing{issuers: issuers, policy: c.policy}
+			issuer, _, err := rt.findBestMatchHost(&InitAuthenRequest{Info1: testInfo1(c.signing...)}, c.class)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := hex.EncodeToString(issuer); got != c.want {
+				t.Fatalf("issuer = %s, want %s", got, c.want)
+			}
+		})
+	}
+}
+
+func TestFindSpecificHost(t *testing.T) {
+	rt := &routing{issuers: Registry{
+		testKeyIdG1: {{Address: "g1.example.com"}},
+		"81370f0000000000000000000000000000000000": {{Address: "other.example.com"}},
+	}}
+	request := &InitAuthenRequest{Info1: testInfo1(testKeyIdG1)}
+	if _, _, err := rt.findSpecificHost(request, "81370f"); err == nil || !strings.Contains(err.Error(), "ambiguous") {
+		t.Fatalf("findSpecificHost(81370f) = %v, want an ambiguous prefix error", err)
+	}
+	issuer, hosts, err := rt.findSpecificHost(request, "81370f51")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hex.EncodeToString(issuer) != testKeyIdG1 || len(hosts) != 1 || hosts[0].Address != "g1.example.com" {
+		t.Fatalf("findSpecificHost(81370f51) = %x, %v", issuer, hosts)
+	}
+	if _, _, err = rt.findSpecificHost(request, "f54172"); err != errNotFound {
+		t.Fatalf("findSpecificHost(f54172) = %v, want %v", err, errNotFound)
+	}
+}
@@ -0,0 +1,197 @@
+package dump
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	. "github.com/CursedHardware/go-rsp-dump/rsp/types"
+	. "github.com/euicc-go/bertlv"
+	"io"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultProbeTimeout     = 15 * time.Second
+	DefaultProbeConcurrency = 8
+)
+
+type ProbeResult struct {
+	Issuer        string        `json:"issuer"`
+	Host          string        `json:"host"`
+	Handshake     bool          `json:"handshake"`
+	IssuerMatched bool          `json:"issuerMatched"`
+	Latency       time.Duration `json:"latency"`
+	SubjectCode   string        `json:"subjectCode,omitempty"`
+	ReasonCode    string        `json:"reasonCode,omitempty"`
+	Error         string        `json:"error,omitempty"`
+	CheckedAt     time.Time     `json:"checkedAt"`
+}
+
+type ProbeReport struct {
+	CheckedAt time.Time     `json:"checkedAt"`
+	Results   []ProbeResult `json:"results"`
+}
+
+// Prober checks every enabled registry host with a TLS handshake and an
+// InitiateAuthentication using a synthetic euiccInfo1 for the issuer.
+type Prober struct {
+	Client      *http.Client
+	TLSConfig   *tls.Config
+	Timeout     time.Duration
+	Concurrency int
+}
+
+func (r *ProbeResult) OK() bool {
+	return r.Handshake && r.IssuerMatched && r.Error == ""
+}
+
+func (p *Prober) Probe(ctx context.Context, registry Registry) *ProbeReport {
+	type target struct {
+		issuer string
+		host   RegistryHost
+	}
+	var targets []target
+	for _, issuer := range registry.issuers(nil) {
+		for _, host := range registry[issuer] {
+			if host.Enabled == nil || *host.Enabled {
+				targets = append(targets, target{issuer, host})
+			}
+		}
+	}
+	report := &ProbeReport{CheckedAt: time.Now(), Results: make([]ProbeResult, len(targets))}
+	concurrency := p.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultProbeConcurrency
+	}
+	semaphore := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for index, target := range targets {
+		wg.Add(1)
+		semaphore <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+			report.Results[index] = p.ProbeHost(ctx, target.issuer, target.host)
+		}()
+	}
+	wg.Wait()
+	return report
+}
+
+func (p *Prober) ProbeHost(ctx context.Context, issuer string, host RegistryHost) (result ProbeResult) {
+	result = ProbeResult{Issuer: issuer, Host: host.Endpoint(), CheckedAt: time.Now()}
+	timeout := host.timeout()
+	if timeout <= 0 {
+		timeout = p.Timeout
+	}
+	if timeout <= 0 {
+		timeout = DefaultProbeTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	if err := p.handshake(ctx, result.Host); err != nil {
+		result.Error = err.Error()
+		return
+	}
+	result.Handshake = true
+	keyId, _ := hex.DecodeString(issuer)
+	request := &InitAuthenRequest{
+		Challenge: make([]byte, 16),
+		Address:   result.Host,
+		Info1:     syntheticInfo1(keyId),
+	}
+	_, _ = rand.Read(request.Challenge)
+	protocol := host.protocol(adminProtocolPrefix + defaultAdminProtocol.String())
+	resp := new(InitAuthenResponse)
+	start := time.Now()
+	err := invoke(ctx, p.Client, result.Host, "initiateAuthentication", protocol, request, resp)
+	result.Latency = time.Since(start)
+	if err == nil {
+		err = resp.Header.Err()
+	}
+	var rspError *Error
+	if errors.As(err, &rspError) {
+		result.SubjectCode, result.ReasonCode = rspError.SubjectCode, rspError.ReasonCode
+	}
+	if err != nil {
+		result.Error = err.Error()
+		return
+	}
+	result.IssuerMatched = resp.UsedIssuer != nil && bytes.Equal(resp.UsedIssuer.Value, keyId)
+	if !result.IssuerMatched {
+		result.Error = "euiccCiPKIdToBeUsed is mismatch"
+	}
+	if resp.TransactionId != "" {
+		cancelRequest := &CancelSessionRequest{TransactionId: resp.TransactionId, Response: cancelSessionUndefinedError}
+		_ = invoke(ctx, p.Client, result.Host, "cancelSession", protocol, cancelRequest, new(GeneralResponse))
+	}
+	return
+}
+
+func (p *Prober) handshake(ctx context.Context, host string) error {
+	address := host
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		address = net.JoinHostPort(host, "443")
+	}
+	config := p.TLSConfig
+	if config == nil {
+		// SM-DP+ TLS certificates are issued by the GSMA CI rather than a public root
+		config = &tls.Config{InsecureSkipVerify: true}
+	}
+	dialer := &tls.Dialer{Config: config}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// EUICCInfo1 ::= [32] SEQUENCE announcing only the probed issuer, SVN 2.2.0
+func syntheticInfo1(keyId []byte) *TLV {
+	return NewChildren(
+		Tag{0xBF, 0x20},
+		NewValue(Tag{0x82}, defaultAdminProtocol[:]),
+		NewChildren(Tag{0xA9}, NewValue(Tag{0x04}, keyId)),
+		NewChildren(Tag{0xAA}, NewValue(Tag{0x04}, keyId)),
+	)
+}
+
+func ReadProbeReport(r io.Reader) (report *ProbeReport, err error) {
+	report = new(ProbeReport)
+	if err = json.NewDecoder(r).Decode(report); err != nil {
+		return nil, err
+	}
+	return
+}
+
+func (r *ProbeReport) WriteTo(w io.Writer) (int64, error) {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return 0, err
+	}
+	n, err := w.Write(append(data, '\n'))
+	return int64(n), err
+}
+
+// Lookup returns the probe result of the issuer and host, if it was probed
+func (r *ProbeReport) Lookup(issuer, host string) (ProbeResult, bool) {
+	if r == nil {
+		return ProbeResult{}, false
+	}
+	index := slices.IndexFunc(r.Results, func(result ProbeResult) bool {
+		return strings.EqualFold(result.Issuer, issuer) && result.Host == host
+	})
+	if index == -1 {
+		return ProbeResult{}, false
+	}
+	return r.Results[index], true
+}
@@ -0,0 +1,129 @@
+package dump
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	. "github.com/CursedHardware/go-rsp-dump/rsp/types"
+	. "github.com/euicc-go/bertlv"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const testProbeIssuer = "81370f5125d0b1d408d4c3b232e6d25e795bebfb"
+
+// mockSMDP answers InitiateAuthentication with respond and records the CancelSession requests
+type mockSMDP struct {
+	*httptest.Server
+	mutex    sync.Mutex
+	cancels  []CancelSessionRequest
+	protocol string
+}
+
+func newMockSMDP(t *testing.T, respond func(*InitAuthenRequest) any) *mockSMDP {
+	t.Helper()
+	mock := new(mockSMDP)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/gsma/rsp2/es9plus/initiateAuthentication", func(w http.ResponseWriter, r *http.Request) {
+		mock.mutex.Lock()
+		mock.protocol = r.Header.Get("X-Admin-Protocol")
+		mock.mutex.Unlock()
+		request := new(InitAuthenRequest)
+		if err := json.NewDecoder(r.Body).Decode(request); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(respond(request))
+	})
+	mux.HandleFunc("/gsma/rsp2/es9plus/cancelSession", func(w http.ResponseWriter, r *http.Request) {
+		var request CancelSessionRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		mock.mutex.Lock()
+		mock.cancels = append(mock.cancels, request)
+		mock.mutex.Unlock()
+		_ = json.NewEncoder(w).Encode(&GeneralResponse{Header: ExecutedSuccess.Header(nil)})
+	})
+	mock.Server = httptest.NewTLSServer(mux)
+	t.Cleanup(mock.Close)
+	return mock
+}
+
+func (m *mockSMDP) probe(t *testing.T) ProbeResult {
+	t.Helper()
+	prober := &Prober{Client: m.Client()}
+	return prober.ProbeHost(context.Background(), testProbeIssuer, RegistryHost{Address: m.Listener.Addr().String()})
+}
+
+func TestProberProbeHost(t *testing.T) {
+	keyId, _ := hex.DecodeString(testProbeIssuer)
+
+	t.Run("success", func(t *testing.T) {
+		mock := newMockSMDP(t, func(request *InitAuthenRequest) any {
+			if !hasKeyId(request.Info1.First(Tag{0xA9}), keyId) {
+				t.Errorf("euiccInfo1 does not announce %s", testProbeIssuer)
+			}
+			return &InitAuthenResponse{
+				Header:        ExecutedSuccess.Header(nil),
+				TransactionId: "0102",
+				UsedIssuer:    NewValue(Tag{0x04}, keyId),
+			}
+		})
+		result := mock.probe(t)
+		if !result.OK() || !result.Handshake || !result.IssuerMatched {
+			t.Fatalf("result = %+v, want OK", result)
+		}
+		if !strings.HasPrefix(mock.protocol, adminProtocolPrefix) {
+			t.Fatalf("X-Admin-Protocol = %q", mock.protocol)
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		mock := newMockSMDP(t, func(*InitAuthenRequest) any {
+			return newError("8.8.2", "3.1", "ciPKNotSupported")
+		})
+		result := mock.probe(t)
+		if result.OK() || !result.Handshake || result.SubjectCode != "8.8.2" || result.ReasonCode != "3.1" {
+			t.Fatalf("result = %+v, want the 8.8.2/3.1 error", result)
+		}
+		if len(mock.cancels) != 0 {
+			t.Fatalf("cancelSession called %d times without a transaction", len(mock.cancels))
+		}
+	})
+
+	t.Run("cancel session", func(t *testing.T) {
+		mock := newMockSMDP(t, func(*InitAuthenRequest) any {
+			return &InitAuthenResponse{
+				Header:        ExecutedSuccess.Header(nil),
+				TransactionId: "0304",
+				UsedIssuer:    NewValue(Tag{0x04}, []byte{0x01, 0x02}),
+			}
+		})
+		result := mock.probe(t)
+		if result.OK() || result.IssuerMatched {
+			t.Fatalf("result = %+v, want euiccCiPKIdToBeUsed mismatch", result)
+		}
+		if len(mock.cancels) != 1 || mock.cancels[0].TransactionId != "0304" {
+			t.Fatalf("cancelSession requests = %+v, want one for 0304", mock.cancels)
+		}
+		got, _ := mock.cancels[0].Response.MarshalBinary()
+		want, _ := NewChildren(Tag{0xBF, 0x41}, NewValue(Tag{0x81}, []byte{0x7F})).MarshalBinary()
+		if !bytes.Equal(got, want) {
+			t.Fatalf("cancelSessionResponse = %X, want %X", got, want)
+		}
+	})
+
+	t.Run("handshake", func(t *testing.T) {
+		mock := newMockSMDP(t, nil)
+		mock.Close()
+		if result := mock.probe(t); result.Handshake || result.Error == "" {
+			t.Fatalf("result = %+v, want a handshake failure", result)
+		}
+	})
+}
@@ -0,0 +1,39 @@
+package dump
+
+import (
+	"fmt"
+	. "github.com/euicc-go/bertlv"
+)
+
+const adminProtocolPrefix = "gsma/rsp/v"
+
+var (
+	minAdminProtocol     = Version{2, 0, 0}
+	maxAdminProtocol     = Version{3, 1, 0}
+	defaultAdminProtocol = Version{2, 2, 0}
+)
+
+func parseAdminProtocol(header string) (version Version, err error) {
+	_, err = fmt.Sscanf(header, adminProtocolPrefix+"%d.%d.%d", &version[0], &version[1], &version[2])
+	if err != nil || header != adminProtocolPrefix+version.String() {
+		err = fmt.Errorf("invalid X-Admin-Protocol: %q", header)
+	}
+	return
+}
+
+func supportedAdminProtocol(version Version) bool {
+	return version.Compare(minAdminProtocol) >= 0 && version[0] <= maxAdminProtocol[0]
+}
+
+func negotiateProtocol(header string, info1 *TLV) string {
+	if version, err := parseAdminProtocol(header); err == nil && supportedAdminProtocol(version) {
+		return header
+	}
+	svn := defaultAdminProtocol
+	if info1 != nil {
+		if version := info1.First(Tag{0x82}); version != nil && len(version.Value) == 3 {
+			svn = Version(version.Value)
+		}
+	}
+	return adminProtocolPrefix + svn.String()
+}
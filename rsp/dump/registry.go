@@ -0,0 +1,314 @@
+package dump
+
+import (
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const RegistryTableURL = "https://github.com/CursedHardware/gsma-rsp-certificates/raw/main/registry.csv"
+
+var hostnamePattern = regexp.MustCompile(`^(?i)[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?(\.[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?)+$`)
+
+// Registry maps an issuer key id to its SM-DP+ hosts, each host is either
+// a bare address or an object carrying RegistryHost metadata.
+type Registry map[string][]RegistryHost
+
+type RegistryHost struct {
+	Address       string   `json:"address"`
+	Alias         string   `json:"alias,omitempty"`
+	Name          string   `json:"name,omitempty"`
+	Enabled       *bool    `json:"enabled,omitempty"`
+	Weight        float64  `json:"weight,omitempty"`
+	Port          uint16   `json:"port,omitempty"`
+	MinSVN        *Version `json:"min_svn,omitempty"`
+	MaxSVN        *Version `json:"max_svn,omitempty"`
+	AdminProtocol *Version `json:"admin_protocol,omitempty"`
+	Timeout       float64  `json:"timeout,omitempty"`
+}
+
+type RegistryFilter struct {
+	Include []string
+	Exclude []string
+}
+
+type RegistryChange struct {
+	Issuer string
+	Host   string
+	Added  bool
+}
+
+// ParseRegistryCSV skips the rows with an invalid issuer or host, they are returned as warnings
+func ParseRegistryCSV(r io.Reader, filter RegistryFilter) (registry Registry, warnings []error, err error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return
+	}
+	issuerIndex := slices.Index(header, "issuer")
+	addressIndex := slices.Index(header, "smdp_address")
+	if issuerIndex == -1 || addressIndex == -1 {
+		return nil, nil, errors.New("registry: missing issuer or smdp_address column")
+	}
+	registry = make(Registry)
+	for line := 2; ; line++ {
+		var record []string
+		if record, err = reader.Read(); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, warnings, err
+		}
+		if len(record) <= max(issuerIndex, addressIndex) {
+			warnings = append(warnings, fmt.Errorf("registry: line %d: %d columns", line, len(record)))
+			continue
+		}
+		issuer := strings.ToLower(strings.TrimSpace(record[issuerIndex]))
+		host := strings.ToLower(strings.TrimSpace(record[addressIndex]))
+		if err = validateKeyId(issuer); err != nil {
+			warnings = append(warnings, fmt.Errorf("registry: line %d: %w", line, err))
+			continue
+		}
+		if err = validateHost(host); err != nil {
+			warnings = append(warnings, fmt.Errorf("registry: line %d: %w", line, err))
+			continue
+		}
+		if filter.allowed(host) {
+			registry.add(issuer, RegistryHost{Address: host})
+		}
+	}
+	registry.normalize()
+	return registry, warnings, nil
+}
+
+func ReadRegistry(r io.Reader) (registry Registry, err error) {
+	if err = json.NewDecoder(r).Decode(&registry); err != nil {
+		return
+	}
+	for issuer, hosts := range registry {
+		if err = validateKeyId(issuer); err != nil {
+			return nil, fmt.Errorf("registry: %w", err)
+		}
+		aliases := make(map[string]bool)
+		for _, host := range hosts {
+			if err = validateHost(host.Address); err != nil {
+				return nil, fmt.Errorf("registry: %w", err)
+			}
+			if host.AdminProtocol != nil && !supportedAdminProtocol(*host.AdminProtocol) {
+				return nil, fmt.Errorf("registry: unsupported admin protocol %s for host %q", host.AdminProtocol, host.Address)
+			}
+			if host.Alias == "" {
+				continue
+			}
+			if !aliasPattern.MatchString(host.Alias) {
+				return nil, fmt.Errorf("registry: invalid alias %q for host %q", host.Alias, host.Address)
+			}
+			if aliases[host.Alias] {
+				return nil, fmt.Errorf("registry: duplicate alias %q for issuer %s", host.Alias, issuer)
+			}
+			aliases[host.Alias] = true
+		}
+	}
+	return
+}
+
+func (r Registry) WriteTo(w io.Writer) (int64, error) {
+	normalized := make(map[string][]RegistryHost, len(r))
+	for issuer, hosts := range r {
+		normalized[issuer] = slices.SortedFunc(slices.Values(hosts), compareHosts)
+	}
+	data, err := json.MarshalIndent(normalized, "", "  ")
+	if err != nil {
+		return 0, err
+	}
+	n, err := w.Write(append(data, '\n'))
+	return int64(n), err
+}
+
+// Inherit copies the metadata of hosts also present in previous,
+// so a registry sync keeps the per-host settings edited by hand.
+func (r Registry) Inherit(previous Registry) {
+	for issuer, hosts := range r {
+		for index, host := range hosts {
+			for _, candidate := range previous[issuer] {
+				if candidate.Address == host.Address {
+					hosts[index] = candidate
+				}
+			}
+		}
+	}
+}
+
+func (r Registry) Diff(next Registry) (changes []RegistryChange) {
+	for _, issuer := range r.issuers(next) {
+		current, upcoming := addresses(r[issuer]), addresses(next[issuer])
+		for _, host := range current {
+			if !slices.Contains(upcoming, host) {
+				changes = append(changes, RegistryChange{Issuer: issuer, Host: host})
+			}
+		}
+		for _, host := range upcoming {
+			if !slices.Contains(current, host) {
+				changes = append(changes, RegistryChange{Issuer: issuer, Host: host, Added: true})
+			}
+		}
+	}
+	return
+}
+
+func (c RegistryChange) String() string {
+	if c.Added {
+		return fmt.Sprintf("+ %s %s", c.Issuer, c.Host)
+	}
+	return fmt.Sprintf("- %s %s", c.Issuer, c.Host)
+}
+
+func (h *RegistryHost) UnmarshalJSON(data []byte) error {
+	var address string
+	if err := json.Unmarshal(data, &address); err == nil {
+		*h = RegistryHost{Address: address}
+		return nil
+	}
+	type plain RegistryHost
+	return json.Unmarshal(data, (*plain)(h))
+}
+
+func (h RegistryHost) MarshalJSON() ([]byte, error) {
+	if h == (RegistryHost{Address: h.Address}) {
+		return json.Marshal(h.Address)
+	}
+	type plain RegistryHost
+	return json.Marshal(plain(h))
+}
+
+// Endpoint is the host[:port] used to reach the SM-DP+
+func (h *RegistryHost) Endpoint() string {
+	if h.Port == 0 {
+		return h.Address
+	}
+	host := h.Address
+	if split, _, err := net.SplitHostPort(h.Address); err == nil {
+		host = split
+	}
+	return net.JoinHostPort(host, strconv.Itoa(int(h.Port)))
+}
+
+func (h *RegistryHost) Label() string {
+	if h.Name == "" {
+		return h.Endpoint()
+	}
+	return fmt.Sprintf("%s (%s)", h.Endpoint(), h.Name)
+}
+
+// Supports reports whether the host accepts an eUICC announcing the SVN range [lowest, highest]
+func (h *RegistryHost) Supports(lowest, highest Version) bool {
+	if h.Enabled != nil && !*h.Enabled {
+		return false
+	}
+	if h.MinSVN != nil && highest.Compare(*h.MinSVN) < 0 {
+		return false
+	}
+	return h.MaxSVN == nil || lowest.Compare(*h.MaxSVN) <= 0
+}
+
+// protocol is the X-Admin-Protocol sent upstream, the host override wins over the negotiated one
+func (h *RegistryHost) protocol(negotiated string) string {
+	if h.AdminProtocol == nil {
+		return negotiated
+	}
+	return adminProtocolPrefix + h.AdminProtocol.String()
+}
+
+func (h *RegistryHost) weight() float64 {
+	if h.Weight <= 0 {
+		return 1
+	}
+	return h.Weight
+}
+
+func (h *RegistryHost) timeout() time.Duration {
+	return time.Duration(h.Timeout * float64(time.Second))
+}
+
+func (r Registry) add(issuer string, host RegistryHost) {
+	if !slices.Contains(addresses(r[issuer]), host.Address) {
+		r[issuer] = append(r[issuer], host)
+	}
+}
+
+func (r Registry) normalize() {
+	for issuer, hosts := range r {
+		if len(hosts) == 0 {
+			delete(r, issuer)
+			continue
+		}
+		slices.SortFunc(hosts, compareHosts)
+	}
+}
+
+func (r Registry) issuers(other Registry) []string {
+	issuers := make([]string, 0, len(r)+len(other))
+	for issuer := range r {
+		issuers = append(issuers, issuer)
+	}
+	for issuer := range other {
+		if _, ok := r[issuer]; !ok {
+			issuers = append(issuers, issuer)
+		}
+	}
+	slices.Sort(issuers)
+	return issuers
+}
+
+func (f RegistryFilter) allowed(host string) bool {
+	if len(f.Include) > 0 && !slices.Contains(f.Include, host) {
+		return false
+	}
+	return !slices.Contains(f.Exclude, host)
+}
+
+func addresses(hosts []RegistryHost) []string {
+	addresses := make([]string, len(hosts))
+	for index, host := range hosts {
+		addresses[index] = host.Address
+	}
+	return addresses
+}
+
+func compareHosts(a, b RegistryHost) int {
+	return strings.Compare(a.Address, b.Address)
+}
+
+func validateKeyId(keyId string) error {
+	if decoded, err := hex.DecodeString(keyId); err != nil || len(decoded) == 0 || len(decoded) > 32 {
+		return fmt.Errorf("invalid issuer key id %q", keyId)
+	}
+	return nil
+}
+
+func validateHost(address string) error {
+	host := address
+	if strings.Contains(address, ":") {
+		var port string
+		var err error
+		if host, port, err = net.SplitHostPort(address); err != nil {
+			return fmt.Errorf("invalid host %q", address)
+		}
+		if value, err := strconv.ParseUint(port, 10, 16); err != nil || value == 0 {
+			return fmt.Errorf("invalid port in host %q", address)
+		}
+	}
+	if !hostnamePattern.MatchString(host) && net.ParseIP(host) == nil {
+		return fmt.Errorf("invalid host %q", address)
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package dump
+
+import (
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseRegistryCSV(t *testing.T) {
+	source := strings.Join([]string{
+		"issuer,smdp_address",
+		"81370F5125D0B1D408D4C3B232E6D25E795BEBFB,SMDP.example.com",
+		"81370f5125d0b1d408d4c3b232e6d25e795bebfb,rsp.example.org",
+		"81370f5125d0b1d408d4c3b232e6d25e795bebfb,smdp.example.com",
+		"f54172bdf98a95d65cbeb88a38a1c11d800a85c3,excluded.example.net",
+	}, "\n")
+	registry, warnings, err := ParseRegistryCSV(strings.NewReader(source), RegistryFilter{Exclude: []string{"excluded.example.net"}})
+	if err != nil || len(warnings) > 0 {
+		t.Fatal(err, warnings)
+	}
+	want := Registry{"81370f5125d0b1d408d4c3b232e6d25e795bebfb": {{Address: "rsp.example.org"}, {Address: "smdp.example.com"}}}
+	if !maps.EqualFunc(registry, want, slices.Equal) {
+		t.Fatalf("registry = %v, want %v", registry, want)
+	}
+}
+
+func TestParseRegistryCSVSkipsInvalidRows(t *testing.T) {
+	source := strings.Join([]string{
+		"issuer,smdp_address",
+		"81370f5125d0b1d408d4c3b232e6d25e795bebfb,smdp.example.com",
+		"not-a-key-id,smdp.example.net",
+		"81370f5125d0b1d408d4c3b232e6d25e795bebfb,invalid host",
+		"81370f5125d0b1d408d4c3b232e6d25e795bebfb",
+		"81370f5125d0b1d408d4c3b232e6d25e795bebfb,rsp.example.org",
+	}, "\n")
+	registry, warnings, err := ParseRegistryCSV(strings.NewReader(source), RegistryFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(warnings) != 3 {
+		t.Fatalf("warnings = %v, want 3", warnings)
+	}
+	hosts := registry["81370f5125d0b1d408d4c3b232e6d25e795bebfb"]
+	if len(hosts) != 2 {
+		t.Fatalf("hosts = %v, want smdp.example.com and rsp.example.org", hosts)
+	}
+}
+
+func TestRegistryDiff(t *testing.T) {
+	current := Registry{"81370f5125d0b1d408d4c3b232e6d25e795bebfb": {{Address: "rsp.example.org"}, {Address: "smdp.example.com"}}}
+	next := Registry{
+		"81370f5125d0b1d408d4c3b232e6d25e795bebfb": {{Address: "smdp.example.com"}},
+		"f54172bdf98a95d65cbeb88a38a1c11d800a85c3": {{Address: "test.example.net"}},
+	}
+	var changes []string
+	for _, change := range current.Diff(next) {
+		changes = append(changes, change.String())
+	}
+	want := []string{
+		"- 81370f5125d0b1d408d4c3b232e6d25e795bebfb rsp.example.org",
+		"+ f54172bdf98a95d65cbeb88a38a1c11d800a85c3 test.example.net",
+	}
+	if !slices.Equal(changes, want) {
+		t.Fatalf("Diff = %q, want %q", changes, want)
+	}
+}
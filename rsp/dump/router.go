@@ -0,0 +1,168 @@
+package dump
+
+import (
+	"encoding/json"
+	"errors"
+	. "github.com/euicc-go/bertlv"
+	"net/http"
+	"sync"
+)
+
+type Binding string
+
+const (
+	BindingJSON Binding = "json"
+	BindingASN1 Binding = "asn1"
+)
+
+type Request struct {
+	Function      string
+	Binding       Binding
+	AdminProtocol string
+	Body          any
+	HTTP          *http.Request
+}
+
+type FunctionHandler func(*Request) (any, error)
+
+type Middleware func(FunctionHandler) FunctionHandler
+
+type Route[Req, Resp any] struct {
+	Path       string
+	Tag        Tag
+	Handle     func(*Request, *Req) (*Resp, error)
+	DecodeASN1 func(*TLV) (*Req, error)
+	EncodeASN1 func(*Resp, error) *TLV
+}
+
+type Router struct {
+	mutex       sync.RWMutex
+	middlewares []Middleware
+	paths       map[string]*route
+	tags        map[string]*route
+}
+
+type route struct {
+	path       string
+	newRequest func() any
+	handle     FunctionHandler
+	decodeASN1 func(*TLV) (any, error)
+	encodeASN1 func(any, error) *TLV
+}
+
+func NewRouter() *Router {
+	return &Router{paths: make(map[string]*route), tags: make(map[string]*route)}
+}
+
+func Handle[Req, Resp any](router *Router, r Route[Req, Resp]) {
+	entry := &route{
+		path:       r.Path,
+		newRequest: func() any { return new(Req) },
+		handle: func(request *Request) (any, error) {
+			return r.Handle(request, request.Body.(*Req))
+		},
+	}
+	if r.Tag != nil && r.DecodeASN1 != nil && r.EncodeASN1 != nil {
+		entry.decodeASN1 = func(tlv *TLV) (any, error) { return r.DecodeASN1(tlv) }
+		entry.encodeASN1 = func(response any, err error) *TLV {
+			typed, _ := response.(*Resp)
+			return r.EncodeASN1(typed, err)
+		}
+	}
+	router.mutex.Lock()
+	defer router.mutex.Unlock()
+	router.paths[r.Path] = entry
+	// a tag answers a single route on /gsma/rsp2/asn1, the first one registered with it
+	if _, taken := router.tags[string(r.Tag)]; entry.decodeASN1 != nil && !taken {
+		router.tags[string(r.Tag)] = entry
+	}
+}
+
+func (r *Router) Use(middlewares ...Middleware) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.middlewares = append(r.middlewares, middlewares...)
+}
+
+func (r *Router) dispatch(entry *route, request *Request) (any, error) {
+	r.mutex.RLock()
+	handler := entry.handle
+	for index := len(r.middlewares) - 1; index >= 0; index-- {
+		handler = r.middlewares[index](handler)
+	}
+	r.mutex.RUnlock()
+	return handler(request)
+}
+
+func (r *Router) serveJSON(w http.ResponseWriter, req *http.Request, path, protocol string) {
+	w.Header().Set("Content-Type", "application/json;charset=UTF-8")
+	r.mutex.RLock()
+	entry, ok := r.paths[path]
+	r.mutex.RUnlock()
+	var response any
+	var err error
+	if !ok {
+		err = newError("1.6", "3.1", "function not supported: %s", path)
+	} else {
+		request := &Request{Function: path, Binding: BindingJSON, AdminProtocol: protocol, HTTP: req}
+		request.Body = entry.newRequest()
+		if err = json.NewDecoder(req.Body).Decode(request.Body); err != nil {
+			err = newError(errMalformedRequest.SubjectCode, errMalformedRequest.ReasonCode, "%s (%s)", errMalformedRequest.Message, err)
+		} else {
+			response, err = r.dispatch(entry, request)
+		}
+	}
+	if badRequest(err) {
+		w.WriteHeader(http.StatusBadRequest)
+	}
+	if err != nil {
+		response = toError(err)
+	}
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+// badRequest is answered with 400 on both bindings, the body still carries the function error
+func badRequest(err error) bool {
+	return errors.Is(err, errMalformedRequest) || errors.Is(err, errMissingElement)
+}
+
+func (r *Router) serveASN1(w http.ResponseWriter, req *http.Request, protocol string) {
+	tlv := new(TLV)
+	if _, err := tlv.ReadFrom(req.Body); err != nil {
+		http.Error(w, errMalformedRequest.Error(), http.StatusBadRequest)
+		return
+	}
+	entry, body := r.lookupASN1(tlv)
+	if entry == nil {
+		http.Error(w, "function not supported", http.StatusBadRequest)
+		return
+	}
+	request := &Request{Function: entry.path, Binding: BindingASN1, AdminProtocol: protocol, HTTP: req}
+	var response any
+	var err error
+	if request.Body, err = entry.decodeASN1(body); err == nil {
+		response, err = r.dispatch(entry, request)
+	}
+	w.Header().Set("Content-Type", "application/x-gsma-rsp-asn1")
+	if badRequest(err) {
+		w.WriteHeader(http.StatusBadRequest)
+	}
+	if encoded := entry.encodeASN1(response, err); encoded != nil {
+		_, _ = encoded.WriteTo(w)
+	}
+}
+
+// RemoteProfileProvisioningRequest ::= [2] CHOICE, the bare request is accepted as well
+func (r *Router) lookupASN1(tlv *TLV) (*route, *TLV) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	if entry, ok := r.tags[string(tlv.Tag)]; ok {
+		return entry, tlv
+	}
+	for _, child := range tlv.Children {
+		if entry, ok := r.tags[string(child.Tag)]; ok {
+			return entry, child
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,107 @@
+package dump
+
+import (
+	_ "embed"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// defaultSASCatalog holds the bundled SAS-UP sites, sas_catalog_file adds to them or overrides them
+//
+//go:embed sas-catalog.json
+var defaultSASCatalog []byte
+
+var sasCatalog = newCatalog("sas", defaultSASCatalog, func(site SASSite) string { return normalizeSAS(site.AccreditationNumber) }, validateSASCatalog)
+
+const (
+	SASValid       = "valid"
+	SASNotYetValid = "not-yet-valid"
+	SASExpired     = "expired"
+	SASUnknown     = "unknown"
+)
+
+// SASSite is a production site certified by the GSMA Security Accreditation Scheme (SAS-UP),
+// ValidFrom and ValidUntil are dates in the 2006-01-02 layout.
+type SASSite struct {
+	AccreditationNumber string `json:"accreditationNumber"`
+	Manufacturer        string `json:"manufacturer"`
+	Site                string `json:"site"`
+	ValidFrom           string `json:"validFrom,omitempty"`
+	ValidUntil          string `json:"validUntil"`
+}
+
+// SASCatalog maps the sasAcreditationNumber of EUICCInfo2 to its production site
+type SASCatalog []SASSite
+
+// SASAccreditation is the catalog lookup of the sasAcreditationNumber announced by the eUICC
+type SASAccreditation struct {
+	Number string   `json:"accreditationNumber"`
+	Status string   `json:"status"`
+	Site   *SASSite `json:"site,omitempty"`
+}
+
+func ReadSASCatalog(r io.Reader) (SASCatalog, error) {
+	return sasCatalog.read(r)
+}
+
+// SetSASCatalog installs the SAS-UP sites of sas_catalog_file,
+// a site replaces the bundled one with the same accreditation number.
+func SetSASCatalog(catalog SASCatalog) error {
+	return sasCatalog.set(catalog)
+}
+
+func validateSASCatalog(catalog []SASSite) error {
+	for _, site := range catalog {
+		if strings.TrimSpace(site.AccreditationNumber) == "" {
+			return fmt.Errorf("empty accreditation number for %q", site.Site)
+		}
+		if _, err := time.Parse(time.DateOnly, site.ValidUntil); err != nil {
+			return fmt.Errorf("%s: invalid validUntil %q", site.AccreditationNumber, site.ValidUntil)
+		}
+		if site.ValidFrom == "" {
+			continue
+		}
+		if _, err := time.Parse(time.DateOnly, site.ValidFrom); err != nil {
+			return fmt.Errorf("%s: invalid validFrom %q", site.AccreditationNumber, site.ValidFrom)
+		}
+	}
+	return nil
+}
+
+func LookupSAS(number string) *SASSite {
+	catalog := sasCatalog.load()
+	for index := range catalog {
+		if normalizeSAS(catalog[index].AccreditationNumber) == normalizeSAS(number) {
+			return &catalog[index]
+		}
+	}
+	return nil
+}
+
+// CheckSAS looks the accreditation number up, at now an accreditation is not yet valid
+// before its first valid day and expired after its last one.
+func CheckSAS(number string, now time.Time) *SASAccreditation {
+	accreditation := &SASAccreditation{Number: number, Status: SASUnknown}
+	if accreditation.Site = LookupSAS(number); accreditation.Site == nil {
+		return accreditation
+	}
+	accreditation.Status = SASValid
+	// the accreditation is valid from the start of its first day until the end of its last day
+	until, _ := time.Parse(time.DateOnly, accreditation.Site.ValidUntil)
+	if !now.Before(until.AddDate(0, 0, 1)) {
+		accreditation.Status = SASExpired
+	}
+	if accreditation.Site.ValidFrom == "" {
+		return accreditation
+	}
+	if from, _ := time.Parse(time.DateOnly, accreditation.Site.ValidFrom); now.Before(from) {
+		accreditation.Status = SASNotYetValid
+	}
+	return accreditation
+}
+
+func normalizeSAS(number string) string {
+	return strings.ToUpper(strings.TrimSpace(number))
+}
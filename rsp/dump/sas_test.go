@@ -0,0 +1,33 @@
+package dump
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCheckSAS(t *testing.T) {
+	t.Cleanup(func() { _ = SetSASCatalog(nil) })
+	err := SetSASCatalog(SASCatalog{
+		{AccreditationNumber: "XX-YY-UP-0001", Manufacturer: "Example EUM", Site: "Example City", ValidFrom: "2024-01-01", ValidUntil: "2025-12-31"},
+		{AccreditationNumber: "XX-YY-UP-0002", Manufacturer: "Example EUM", Site: "Example Town", ValidUntil: "2025-12-31"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		number, now, status string
+	}{
+		{"XX-YY-UP-0001", "2023-12-31T23:59:59Z", SASNotYetValid},
+		{"XX-YY-UP-0001", "2024-01-01T00:00:00Z", SASValid},
+		{" xx-yy-up-0001 ", "2025-12-31T23:59:59Z", SASValid},
+		{"XX-YY-UP-0001", "2026-01-01T00:00:00Z", SASExpired},
+		{"XX-YY-UP-0002", "2000-01-01T00:00:00Z", SASValid},
+		{"XX-YY-UP-0003", "2025-01-01T00:00:00Z", SASUnknown},
+	}
+	for _, c := range cases {
+		now, _ := time.Parse(time.RFC3339, c.now)
+		if accreditation := CheckSAS(c.number, now); accreditation.Status != c.status {
+			t.Errorf("CheckSAS(%q, %s) = %s, want %s", c.number, c.now, accreditation.Status, c.status)
+		}
+	}
+}
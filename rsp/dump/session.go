@@ -0,0 +1,226 @@
+package dump
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	. "github.com/euicc-go/bertlv"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const DefaultSessionTTL = 10 * time.Minute
+
+type Session struct {
+	TransactionId    string    `json:"transactionId"`
+	Host             string    `json:"host"`
+	Operator         string    `json:"operator,omitempty"`
+	Issuer           HexString `json:"issuer"`
+	Challenge        HexString `json:"euiccChallenge"`
+	Info1            *TLV      `json:"euiccInfo1"`
+	LPARSPCapability *TLV      `json:"lpaRspCapability,omitempty"`
+	AdminProtocol    string    `json:"adminProtocol,omitempty"`
+	CreatedAt        time.Time `json:"createdAt"`
+}
+
+type SessionStore interface {
+	Load(transactionId string) (*Session, bool)
+	Store(session *Session) error
+	Delete(transactionId string) error
+}
+
+type MemorySessionStore struct {
+	TTL      time.Duration
+	mutex    sync.Mutex
+	sessions map[string]*Session
+}
+
+func NewMemorySessionStore(ttl time.Duration) *MemorySessionStore {
+	if ttl <= 0 {
+		ttl = DefaultSessionTTL
+	}
+	return &MemorySessionStore{TTL: ttl, sessions: make(map[string]*Session)}
+}
+
+func (s *MemorySessionStore) Load(transactionId string) (*Session, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	session, ok := s.sessions[sessionKey(transactionId)]
+	if !ok || s.expired(session) {
+		return nil, false
+	}
+	return session, true
+}
+
+func (s *MemorySessionStore) Store(session *Session) error {
+	if err := prepareSession(session); err != nil {
+		return err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.evict()
+	s.sessions[sessionKey(session.TransactionId)] = session
+	return nil
+}
+
+func (s *MemorySessionStore) Delete(transactionId string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.sessions, sessionKey(transactionId))
+	return nil
+}
+
+func (s *MemorySessionStore) expired(session *Session) bool {
+	return time.Since(session.CreatedAt) > s.TTL
+}
+
+func (s *MemorySessionStore) evict() {
+	for key, session := range s.sessions {
+		if s.expired(session) {
+			delete(s.sessions, key)
+		}
+	}
+}
+
+func prepareSession(session *Session) error {
+	if session == nil || session.TransactionId == "" {
+		return errors.New("rsp-dump: session without transaction id")
+	}
+	if session.CreatedAt.IsZero() {
+		session.CreatedAt = time.Now()
+	}
+	return nil
+}
+
+// FileSessionStore keeps the sessions in memory and appends every change to a
+// JSON lines journal at Path, so a store or delete costs one write instead of
+// rewriting all sessions. The journal is compacted when it is opened and once
+// the stale records outnumber the live sessions.
+type FileSessionStore struct {
+	*MemorySessionStore
+	Path    string
+	journal *os.File
+	records int
+}
+
+type sessionRecord struct {
+	Session *Session `json:"session,omitempty"`
+	Delete  string   `json:"delete,omitempty"`
+}
+
+const sessionCompactSlack = 64
+
+func NewFileSessionStore(path string, ttl time.Duration) (store *FileSessionStore, err error) {
+	store = &FileSessionStore{MemorySessionStore: NewMemorySessionStore(ttl), Path: path}
+	file, err := os.Open(path)
+	if err == nil {
+		store.replay(file)
+		file.Close()
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	if err = store.compact(); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// replay applies the journal records in order. A record that cannot be decoded,
+// such as a line cut short by a crash, ends the journal; compact drops it.
+func (s *FileSessionStore) replay(r io.Reader) {
+	decoder := json.NewDecoder(r)
+	for {
+		var record sessionRecord
+		if err := decoder.Decode(&record); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			log.Printf("rsp-dump: session journal %s: %v, discarding the remaining records", s.Path, err)
+			break
+		}
+		switch {
+		case record.Session != nil && record.Session.TransactionId != "":
+			s.sessions[sessionKey(record.Session.TransactionId)] = record.Session
+		case record.Delete != "":
+			delete(s.sessions, sessionKey(record.Delete))
+		}
+	}
+	s.evict()
+}
+
+func (s *FileSessionStore) Store(session *Session) error {
+	if err := prepareSession(session); err != nil {
+		return err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.evict()
+	s.sessions[sessionKey(session.TransactionId)] = session
+	return s.append(sessionRecord{Session: session})
+}
+
+func (s *FileSessionStore) Delete(transactionId string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.sessions, sessionKey(transactionId))
+	return s.append(sessionRecord{Delete: transactionId})
+}
+
+// Close closes the journal, the store must not be used afterwards.
+func (s *FileSessionStore) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.journal.Close()
+}
+
+func (s *FileSessionStore) append(record sessionRecord) error {
+	if s.records > 2*len(s.sessions)+sessionCompactSlack {
+		s.evict()
+		return s.compact()
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if _, err = s.journal.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	s.records++
+	return nil
+}
+
+// compact replaces the journal with one record per live session and reopens
+// it for appending, the caller holds the mutex.
+func (s *FileSessionStore) compact() (err error) {
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	for _, session := range s.sessions {
+		if err = encoder.Encode(sessionRecord{Session: session}); err != nil {
+			return
+		}
+	}
+	temporary := s.Path + ".tmp"
+	if err = os.WriteFile(temporary, buffer.Bytes(), 0600); err != nil {
+		return
+	}
+	if err = os.Rename(temporary, s.Path); err != nil {
+		return
+	}
+	if s.journal != nil {
+		s.journal.Close()
+	}
+	if s.journal, err = os.OpenFile(s.Path, os.O_WRONLY|os.O_APPEND, 0600); err != nil {
+		return
+	}
+	s.records = len(s.sessions)
+	return
+}
+
+func sessionKey(transactionId string) string {
+	return strings.ToUpper(transactionId)
+}
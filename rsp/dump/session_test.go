@@ -0,0 +1,109 @@
+package dump
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileSessionStorePersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sessions.json")
+	store, err := NewFileSessionStore(path, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, transactionId := range []string{"0A0B", "0C0D"} {
+		if err = store.Store(&Session{TransactionId: transactionId, Host: "smdp.example.com"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = store.Delete("0c0d"); err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+	if store, err = NewFileSessionStore(path, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if session, ok := store.Load("0a0b"); !ok || session.Host != "smdp.example.com" {
+		t.Fatalf("Load(0a0b) = %v, %v, want the stored session", session, ok)
+	}
+	if _, ok := store.Load("0C0D"); ok {
+		t.Fatal("Load(0C0D) found a deleted session")
+	}
+}
+
+func TestFileSessionStoreEvictsExpired(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sessions.json")
+	store, err := NewFileSessionStore(path, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired := &Session{TransactionId: "0A0B", CreatedAt: time.Now().Add(-2 * time.Minute)}
+	if err = store.Store(expired); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := store.Load("0A0B"); ok {
+		t.Fatal("Load returned an expired session")
+	}
+	if err = store.Store(&Session{TransactionId: "0C0D"}); err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+	if store, err = NewFileSessionStore(path, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := store.Load("0A0B"); ok {
+		t.Fatal("reopened store kept an expired session")
+	}
+	if _, ok := store.Load("0C0D"); !ok {
+		t.Fatal("reopened store lost a live session")
+	}
+}
+
+func TestFileSessionStoreCompactsJournal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sessions.json")
+	store, err := NewFileSessionStore(path, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	for index := range 500 {
+		transactionId := fmt.Sprintf("%04X", index)
+		if err = store.Store(&Session{TransactionId: transactionId}); err != nil {
+			t.Fatal(err)
+		}
+		if err = store.Delete(transactionId); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = store.Store(&Session{TransactionId: "FFFF"}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if records := bytes.Count(data, []byte("\n")); records > 2*sessionCompactSlack {
+		t.Fatalf("journal has %d records after compaction", records)
+	}
+}
+
+func TestFileSessionStoreTruncatedJournal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sessions.json")
+	journal := `{"session":{"transactionId":"0A0B","createdAt":"` + time.Now().Format(time.RFC3339) + `"}}` + "\n" +
+		`{"session":{"transactionId":"0C`
+	if err := os.WriteFile(path, []byte(journal), 0600); err != nil {
+		t.Fatal(err)
+	}
+	store, err := NewFileSessionStore(path, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	if _, ok := store.Load("0A0B"); !ok {
+		t.Fatal("lost the session before the truncated record")
+	}
+}
@@ -0,0 +1,145 @@
+package dump
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	. "github.com/CursedHardware/go-rsp-dump/rsp/types"
+	. "github.com/euicc-go/bertlv"
+	"log"
+	"os"
+	"strings"
+)
+
+type LocalSigner struct {
+	Certificate *x509.Certificate
+	PrivateKey  *ecdsa.PrivateKey
+	certificate *TLV
+}
+
+func NewLocalSigner(certFile, keyFile string) (*LocalSigner, error) {
+	if err := checkSigningCurve(certFile); err != nil {
+		return nil, err
+	}
+	pair, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := pair.PrivateKey.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("rsp-dump: signing key is not an ECDSA private key")
+	}
+	certificate, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return nil, err
+	}
+	if len(certificate.AuthorityKeyId) == 0 {
+		return nil, errors.New("rsp-dump: signing certificate without authority key identifier")
+	}
+	signer := &LocalSigner{Certificate: certificate, PrivateKey: key, certificate: new(TLV)}
+	if err = signer.certificate.UnmarshalBinary(certificate.Raw); err != nil {
+		return nil, err
+	}
+	return signer, nil
+}
+
+// checkSigningCurve rejects a certificate on a curve crypto/ecdsa cannot sign with,
+// brainpool.go only verifies and its arithmetic is not constant time
+func checkSigningCurve(certFile string) error {
+	data, err := os.ReadFile(certFile)
+	if err != nil {
+		return err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil
+	}
+	certificate, err := parseRawCertificate(block.Bytes)
+	if err != nil {
+		return nil
+	}
+	if publicKey, _ := certificate.publicKey(); publicKey != nil {
+		if key, ok := publicKey.(*weierstrassKey); ok {
+			return fmt.Errorf("rsp-dump: signing key on %s is not supported, use a NIST P-256 key (e.g. SK_S_SM_DPauth_ECDSA_NIST)", key.Curve.Name)
+		}
+	}
+	return nil
+}
+
+func (s *LocalSigner) IssuerKeyId() []byte {
+	return s.Certificate.AuthorityKeyId
+}
+
+func (s *LocalSigner) InitiateAuthentication(r *InitAuthenRequest) (*InitAuthenResponse, error) {
+	transactionId := make([]byte, 16)
+	serverChallenge := make([]byte, 16)
+	_, _ = rand.Read(transactionId)
+	_, _ = rand.Read(serverChallenge)
+	signed1 := NewChildren(
+		Tag{0x30}, // ServerSigned1
+		NewValue(Tag{0x80}, transactionId),
+		NewValue(Tag{0x81}, r.Challenge),
+		NewValue(Tag{0x83}, []byte(r.Address)),
+		NewValue(Tag{0x84}, serverChallenge),
+	)
+	signature, err := s.sign(signed1.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	return &InitAuthenResponse{
+		Header:        ExecutedSuccess.Header(nil),
+		TransactionId: strings.ToUpper(hex.EncodeToString(transactionId)),
+		Signed1:       signed1,
+		Signature1:    NewValue(Tag{0x5F, 0x37}, signature),
+		UsedIssuer:    NewValue(Tag{0x04}, s.IssuerKeyId()),
+		Certificate:   s.certificate,
+	}, nil
+}
+
+// serverSignature1 is the raw r || s concatenation, see SGP.22 section 2.6.7
+func (s *LocalSigner) sign(data []byte) ([]byte, error) {
+	digest := sha256.Sum256(data)
+	r, ss, err := ecdsa.Sign(rand.Reader, s.PrivateKey, digest[:])
+	if err != nil {
+		return nil, err
+	}
+	size := (s.PrivateKey.Curve.Params().BitSize + 7) / 8
+	signature := make([]byte, size*2)
+	r.FillBytes(signature[:size])
+	ss.FillBytes(signature[size:])
+	return signature, nil
+}
+
+func (h *Handler) handleLocalInitAuthen(r *InitAuthenRequest) (resp *InitAuthenResponse, err error) {
+	issuer := h.Signer.IssuerKeyId()
+	if !hasKeyId(r.Info1.First(Tag{0xAA}), issuer) && !hasKeyId(r.Info1.First(Tag{0xB1}), issuer) {
+		return nil, errNotFound
+	}
+	if resp, err = h.Signer.InitiateAuthentication(r); err != nil {
+		return
+	}
+	session := &Session{
+		TransactionId:    resp.TransactionId,
+		Issuer:           issuer,
+		Challenge:        r.Challenge,
+		Info1:            r.Info1,
+		LPARSPCapability: r.LPARSPCapability,
+		AdminProtocol:    negotiateProtocol(r.AdminProtocol, r.Info1),
+	}
+	log.Println(
+		"Local.InitiateAuthenticationResponse",
+		"TransactionId:", resp.TransactionId,
+		"Address:", r.Address,
+		"Issuer:", hex.EncodeToString(issuer),
+	)
+	if err := h.sessions().Store(session); err != nil {
+		log.Println("Local.InitiateAuthenticationResponse", "TransactionId:", resp.TransactionId, "Session:", err)
+	}
+	return
+}
@@ -0,0 +1,95 @@
+package dump
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	. "github.com/CursedHardware/go-rsp-dump/rsp/types"
+	. "github.com/euicc-go/bertlv"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeTestSigningKey(t *testing.T, issuer []byte) (certFile, keyFile string) {
+	ciKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "Test SM-DP+ Auth"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	parent := &x509.Certificate{Subject: pkix.Name{CommonName: "Test CI"}, SubjectKeyId: issuer}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, ciKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	privateKey, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	directory := t.TempDir()
+	certFile = filepath.Join(directory, "CERT_S_SM_DPauth.pem")
+	keyFile = filepath.Join(directory, "SK_S_SM_DPauth.pem")
+	_ = os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	_ = os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: privateKey}), 0600)
+	return
+}
+
+func TestLocalSignerInitiateAuthentication(t *testing.T) {
+	issuer, _ := hex.DecodeString("81370f5125d0b1d408d4c3b232e6d25e795bebfb")
+	signer, err := NewLocalSigner(writeTestSigningKey(t, issuer))
+	if err != nil {
+		t.Fatal(err)
+	}
+	challenge := bytes.Repeat([]byte{0x5A}, 16)
+	resp, err := signer.InitiateAuthentication(&InitAuthenRequest{Challenge: challenge, Address: "smdp.example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(resp.UsedIssuer.Value, issuer) {
+		t.Fatalf("euiccCiPKIdToBeUsed = %X, want %X", resp.UsedIssuer.Value, issuer)
+	}
+	signed1 := resp.Signed1
+	if transactionId := hex.EncodeToString(signed1.First(Tag{0x80}).Value); !strings.EqualFold(transactionId, resp.TransactionId) {
+		t.Fatalf("ServerSigned1 transactionId = %s, want %s", transactionId, resp.TransactionId)
+	}
+	if value := signed1.First(Tag{0x81}).Value; !bytes.Equal(value, challenge) {
+		t.Fatalf("ServerSigned1 euiccChallenge = %X, want %X", value, challenge)
+	}
+	if value := string(signed1.First(Tag{0x83}).Value); value != "smdp.example.com" {
+		t.Fatalf("ServerSigned1 serverAddress = %s", value)
+	}
+	if value := signed1.First(Tag{0x84}).Value; len(value) != 16 {
+		t.Fatalf("ServerSigned1 serverChallenge = %X, want 16 bytes", value)
+	}
+	data, _ := signed1.MarshalBinary()
+	digest := sha256.Sum256(data)
+	signature := resp.Signature1.Value
+	if len(signature) != 64 {
+		t.Fatalf("serverSignature1 = %X, want r || s", signature)
+	}
+	publicKey := signer.Certificate.PublicKey.(*ecdsa.PublicKey)
+	r, s := new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:])
+	if !ecdsa.Verify(publicKey, digest[:], r, s) {
+		t.Fatal("serverSignature1 does not verify with the SM-DP+ certificate")
+	}
+}
+
+func TestLocalSignerRejectsBrainpool(t *testing.T) {
+	_, err := NewLocalSigner("testdata/brainpool-dpauth.pem", "testdata/brainpool-dpauth-key.pem")
+	if err == nil || !strings.Contains(err.Error(), "brainpoolP256r1 is not supported") {
+		t.Fatalf("NewLocalSigner() error = %v, want the brainpool key rejected", err)
+	}
+}
@@ -1,148 +1,290 @@
 package dump
 
 import (
+	"bytes"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	. "github.com/euicc-go/bertlv"
+	"slices"
 	"strings"
+	"time"
 )
 
 type Report struct {
 	MatchingID       string
+	EID              *EID
 	ServerAddress    string
+	EUICCInfo1       *EUICCInfo1
 	EUICCInfo2       EUICCInfo2
+	SAS              *SASAccreditation
+	DeviceInfo       *DeviceInfo
+	LPARSPCapability *Bits
+	EUICCSigned1     *TLV
+	EUICCSignature1  *TLV
 	EUICCCertificate *TLV
 	EUMCertificate   *TLV
+	Session          *Session
+	Verification     *Verification
+	DecodeErrors     DecodeErrors
+	Mismatches       []InfoMismatch
 }
 
 func (r *Report) UnmarshalBerTLV(response *TLV) (err error) {
 	var report Report
+	if len(response.Children) < 4 {
+		return DecodeErrors{{
+			Field:  "authenticateResponseOk",
+			Tag:    response.Tag,
+			Reason: fmt.Sprintf("expected 4 elements, got %d", len(response.Children)),
+		}}
+	}
 	euiccSigned1 := response.At(0) // eUICCSigned1
-	report.ServerAddress = string(euiccSigned1.First(Tag{0x83}).Value)
+	report.EUICCSigned1 = euiccSigned1
+	report.EUICCSignature1 = response.At(1) // eUICCSignature1
+	if address := euiccSigned1.First(Tag{0x83}); address != nil {
+		report.ServerAddress = string(address.Value)
+	}
 	euiccInfo2 := euiccSigned1.First(Tag{0xBF, 0x22})       // eUICCSigned1 -> eUICCInfo2
 	matchingId := euiccSigned1.Select(Tag{0xA0}, Tag{0x80}) // eUICCSigned1 -> ctxParams1 -> MatchingID
+	deviceInfo := euiccSigned1.Select(Tag{0xA0}, Tag{0xA1}) // eUICCSigned1 -> ctxParams1 -> DeviceInfo
 	report.EUICCCertificate = response.At(2)                // eUICC Certificate
 	report.EUMCertificate = response.At(3)                  // EUM Certificate
-	if err = report.EUICCInfo2.UnmarshalBerTLV(euiccInfo2); err != nil {
+	if err = report.EUICCInfo2.UnmarshalBerTLV(euiccInfo2); err != nil && !errors.As(err, &report.DecodeErrors) {
 		return
 	}
 	if matchingId != nil {
 		report.MatchingID = string(matchingId.Value)
 	}
+	if number := report.EUICCInfo2.SASAccreditationNumber; number != "" {
+		report.SAS = CheckSAS(number, time.Now())
+	}
+	if euicc, _ := parseTLVCertificate(report.EUICCCertificate); euicc != nil {
+		subject, _ := euicc.subject()
+		if report.EID, err = ParseEID(rdnValue(subject, oidSerialNumber)); err != nil {
+			report.DecodeErrors = append(report.DecodeErrors, &DecodeError{Field: "eid", Tag: report.EUICCCertificate.Tag, Reason: err.Error()})
+		}
+	}
+	if deviceInfo != nil {
+		report.DeviceInfo = new(DeviceInfo)
+		var problems DecodeErrors
+		if errors.As(report.DeviceInfo.UnmarshalBerTLV(deviceInfo), &problems) {
+			report.DecodeErrors = append(report.DecodeErrors, problems...)
+		}
+	}
 	*r = report
 	return nil
 }
 
-type EUICCInfo2 struct {
-	ProfileVersion              Version         `json:"profileVersion,omitempty"`
-	SVN                         Version         `json:"svn,omitempty"`
-	FirmwareVersion             Version         `json:"euiccFirmwareVer,omitempty"`
-	ExtCardResource             ExtCardResource `json:"extCardResource,omitempty"`
-	UICCCapability              []string        `json:"uiccCapability,omitempty"`
-	TS102241Version             Version         `json:"ts102241Version,omitempty"`
-	GlobalPlatformVersion       Version         `json:"globalplatformVersion,omitempty"`
-	RSPCapability               []string        `json:"rspCapability,omitempty"`
-	IssuerVerification          []HexString     `json:"euiccCiPKIdListForVerification,omitempty"`
-	IssuerSigning               []HexString     `json:"euiccCiPKIdListForSigning,omitempty"`
-	Category                    string          `json:"euiccCategory,omitempty"`
-	ForbiddenProfilePolicyRules []string        `json:"forbiddenProfilePolicyRules,omitempty"`
-	ProtectionProfileVersion    Version         `json:"ppVersion,omitempty"`
-	SASAccreditationNumber      string          `json:"sasAccreditationNumber,omitempty"`
-	CertificationDataObject     *CertData       `json:"certificationDataObject,omitempty"`
-	TreProperties               []string        `json:"treProperties,omitempty"`
-	TreProductReference         string          `json:"treProductReference,omitempty"`
-	ProfilePackageVersions      []Version       `json:"additionalEuiccProfilePackageVersions,omitempty"`
-}
-
-func (e *EUICCInfo2) UnmarshalBerTLV(tlv *TLV) (err error) {
-	info := EUICCInfo2{
-		ProfileVersion:         Version(tlv.First(Tag{0x81}).Value),
-		SVN:                    Version(tlv.First(Tag{0x82}).Value),
-		FirmwareVersion:        Version(tlv.First(Tag{0x83}).Value),
-		Category:               "Other",
-		SASAccreditationNumber: strings.TrimSpace(string(tlv.First(Tag{0x0C}).Value)),
-	}
-	if resource := tlv.First(Tag{0x84}); resource != nil {
-		data, _ := resource.MarshalBinary()
-		data[0] = 0x30
-		if err = resource.UnmarshalBinary(data); err != nil {
-			return
-		}
-		info.ExtCardResource = ExtCardResource{
-			InstallApps: variant(resource.First(Tag{0x81}).Value),
-			FreeNVRAM:   variant(resource.First(Tag{0x82}).Value),
-			FreeRAM:     variant(resource.First(Tag{0x83}).Value),
+func (r *Report) SetSession(session *Session) (err error) {
+	r.Session = session
+	if session == nil {
+		return
+	}
+	if session.Info1 != nil {
+		r.EUICCInfo1 = new(EUICCInfo1)
+		if err = r.EUICCInfo1.UnmarshalBerTLV(session.Info1); err != nil {
+			var problems DecodeErrors
+			if !errors.As(err, &problems) {
+				r.EUICCInfo1 = nil
+				return
+			}
+			r.DecodeErrors = append(r.DecodeErrors, problems...)
+			err = nil
 		}
+		r.Mismatches = r.EUICCInfo1.Compare(&r.EUICCInfo2)
 	}
-	info.UICCCapability = toBits(tlv.First(Tag{0x85}),
-		"Contactless Support", "USIM Support", "ISIM Support", "CSIM Support",
-		"DeviceInfo Extensibility Support",
-		"AkaMilenage", "AkaCave", "AkaTuak128", "AkaTuak256",
-		"RFU1", "RFU2",
-		"GBA Authentication USIM", "GBA Authentication ISIM", "MBMS Authentication USIM",
-		"EAP Client", "JavaCard", "MultOS",
-		"Multiple USIM Support", "Multiple ISIM Support", "Multiple CSIM Support",
-		"Ber TLV File Support", "DF Link Support",
-		"CAT TP", "GET IDENTITY", "profile-a-x25519", "profile-b-p256", "SUCICalculatorAPI",
-	)
-	if version := tlv.First(Tag{0x86}); version != nil {
-		info.TS102241Version = Version(version.Value)
-	}
-	if version := tlv.First(Tag{0x87}); version != nil {
-		info.GlobalPlatformVersion = Version(version.Value)
-	}
-	info.RSPCapability = toBits(tlv.First(Tag{0x88}),
-		"additionalProfile",
-		"crlSupport",
-		"rpmSupport",
-		"testProfileSupport",
-		"deviceInfoExtensibilitySupport",
-	)
-	for _, child := range tlv.First(Tag{0xA9}).Children {
-		info.IssuerVerification = append(info.IssuerVerification, child.Value)
-	}
-	for _, child := range tlv.First(Tag{0xAA}).Children {
-		info.IssuerSigning = append(info.IssuerSigning, child.Value)
-	}
-	if category := tlv.First(Tag{0x8B}); category != nil {
-		categories := []string{"Other", "Basic eUICC", "Medium eUICC", "Contactless eUICC"}
-		info.Category = categories[category.Value[0]]
-	}
-	if ppr := tlv.First(Tag{0x99}); ppr != nil {
-		info.ForbiddenProfilePolicyRules = toBits(ppr, "pprUpdateControl", "ppr1", "ppr2")
-	}
-	if ppVersion := tlv.First(Tag{0x04}); ppVersion != nil {
-		info.ProtectionProfileVersion = Version(ppVersion.Value)
-	}
-	if certData := tlv.First(Tag{0xAC}); certData != nil {
-		info.CertificationDataObject = &CertData{
-			PlatformLabel:    strings.TrimSpace(string(certData.First(Tag{0x80}).Value)),
-			DiscoveryBaseURL: strings.TrimSpace(string(certData.First(Tag{0x81}).Value)),
+	if session.LPARSPCapability != nil {
+		if r.LPARSPCapability, err = NewBits(session.LPARSPCapability.Value, lpaRSPCapabilities); err != nil {
+			r.DecodeErrors = append(r.DecodeErrors, &DecodeError{Field: "lpaRspCapability", Tag: session.LPARSPCapability.Tag, Reason: err.Error()})
+			err = nil
 		}
 	}
-	if properties := tlv.First(Tag{0xAD}); properties != nil {
-		info.TreProperties = toBits(tlv, "isDiscrete", "isIntegrated", "usesRemoteMemory")
+	return
+}
+
+// EUICCInfo1 ::= [32] SEQUENCE, as first announced by the LPA in InitiateAuthentication
+type EUICCInfo1 struct {
+	SVN                Version     `json:"lowestSvn,omitempty"`
+	HighestSVN         *Version    `json:"highestSvn,omitempty"`
+	IssuerVerification []HexString `json:"euiccCiPKIdListForVerification,omitempty"`
+	IssuerSigning      []HexString `json:"euiccCiPKIdListForSigning,omitempty"`
+	IssuerSigningV3    []HexString `json:"euiccCiPKIdListForSigningV3,omitempty"`
+	EUICCRSPCapability *Bits       `json:"euiccRspCapability,omitempty"`
+}
+
+func (e *EUICCInfo1) UnmarshalBerTLV(tlv *TLV) error {
+	var info EUICCInfo1
+	var problems DecodeErrors
+	problem := func(tag Tag, name string, err error) {
+		problems = append(problems, &DecodeError{Field: "euiccInfo1." + name, Tag: tag, Reason: err.Error()})
 	}
-	if reference := tlv.First(Tag{0xAE}); reference != nil {
-		info.TreProductReference = strings.TrimSpace(string(reference.Value))
+	if svn := tlv.First(Tag{0x82}); svn == nil {
+		problem(Tag{0x82}, "lowestSvn", errors.New("missing"))
+	} else if version, err := decodeVersion(svn); err != nil {
+		problem(svn.Tag, "lowestSvn", err)
+	} else {
+		info.SVN = version
 	}
-	if versions := tlv.First(Tag{0xAF}); versions != nil {
-		info.ProfilePackageVersions = make([]Version, len(versions.Children))
-		for index, version := range versions.Children {
-			info.ProfilePackageVersions[index] = Version(version.Value)
+	if svn := tlv.First(Tag{0x93}); svn != nil {
+		if version, err := decodeVersion(svn); err != nil {
+			problem(svn.Tag, "highestSvn", err)
+		} else {
+			info.HighestSVN = &version
+		}
+	}
+	info.IssuerVerification = toKeyIds(tlv.First(Tag{0xA9}))
+	info.IssuerSigning = toKeyIds(tlv.First(Tag{0xAA}))
+	info.IssuerSigningV3 = toKeyIds(tlv.First(Tag{0xB1}))
+	if capability := tlv.First(Tag{0x88}); capability != nil {
+		var err error
+		if info.EUICCRSPCapability, err = NewBits(capability.Value, rspCapabilities); err != nil {
+			problem(capability.Tag, "euiccRspCapability", err)
 		}
 	}
 	*e = info
+	if len(problems) > 0 {
+		return problems
+	}
 	return nil
 }
 
+// InfoMismatch is a field whose value announced by the LPA in euiccInfo1
+// differs from the one signed by the eUICC in euiccInfo2
+type InfoMismatch struct {
+	Field      string `json:"field"`
+	EUICCInfo1 string `json:"euiccInfo1"`
+	EUICCInfo2 string `json:"euiccInfo2"`
+}
+
+func (e *EUICCInfo1) Compare(info2 *EUICCInfo2) (mismatches []InfoMismatch) {
+	compare := func(field, info1, info2 string) {
+		if info1 != info2 {
+			mismatches = append(mismatches, InfoMismatch{Field: field, EUICCInfo1: info1, EUICCInfo2: info2})
+		}
+	}
+	// the key id lists are sets, the order may differ between euiccInfo1 and euiccInfo2
+	compareKeyIds := func(field string, info1, info2 []HexString) {
+		if !sameKeyIds(info1, info2) {
+			compare(field, joinKeyIds(info1), joinKeyIds(info2))
+		}
+	}
+	compare("svn", e.SVN.String(), info2.SVN.String())
+	if e.HighestSVN != nil || info2.HighestSVN != nil {
+		compare("highestSvn", optionalVersion(e.HighestSVN), optionalVersion(info2.HighestSVN))
+	}
+	compareKeyIds("euiccCiPKIdListForVerification", e.IssuerVerification, info2.IssuerVerification)
+	compareKeyIds("euiccCiPKIdListForSigning", e.IssuerSigning, info2.IssuerSigning)
+	if e.IssuerSigningV3 != nil || info2.IssuerSigningV3 != nil {
+		compareKeyIds("euiccCiPKIdListForSigningV3", e.IssuerSigningV3, info2.IssuerSigningV3)
+	}
+	if e.EUICCRSPCapability != nil && info2.RSPCapability != nil {
+		compare("rspCapability", strings.Join(e.EUICCRSPCapability.Names(), ", "), strings.Join(info2.RSPCapability.Names(), ", "))
+	}
+	return
+}
+
+func optionalVersion(version *Version) string {
+	if version == nil {
+		return "absent"
+	}
+	return version.String()
+}
+
+func sameKeyIds(a, b []HexString) bool {
+	set := func(keyIds []HexString) []string {
+		encoded := make([]string, len(keyIds))
+		for index := range keyIds {
+			encoded[index] = keyIds[index].String()
+		}
+		slices.Sort(encoded)
+		return slices.Compact(encoded)
+	}
+	return slices.Equal(set(a), set(b))
+}
+
+func joinKeyIds(keyIds []HexString) string {
+	encoded := make([]string, len(keyIds))
+	for index := range keyIds {
+		encoded[index] = keyIds[index].String()
+	}
+	return strings.Join(encoded, ", ")
+}
+
+var rspCapabilities = []string{
+	"additionalProfile",
+	"crlSupport",
+	"rpmSupport",
+	"testProfileSupport",
+	"deviceInfoExtensibilitySupport",
+	"serviceSpecificDataSupport",
+	"hpccSupport",
+	"serviceProviderMessageSupport",
+	"providerImageSupport",
+	"vendorSpecificExtensionSupport",
+	"dpacSupport",
+	"evictionSupport",
+	"lpaProxySupport",
+	"enterpriseProfilesSupport",
+	"serviceDescriptionSupport",
+	"deviceChangeSupport",
+	"encryptedDeviceChangeDataSupport",
+	"estimatedProfileSizeIndicationSupport",
+	"profileSizeInProfilesInfoSupport",
+	"crlStaplingV3Support",
+	"certChainV3VerificationSupport",
+	"signedSmdsResponseV3Support",
+	"euiccRspCapInInfo1",
+	"osUpdateSupport",
+	"cancelForEmptySpnPnSupport",
+	"updateNotifConfigInfoSupport",
+	"updateMetadataV3Support",
+	"v3ObjectsInCtxParamsCASupport",
+	"pushServiceRegistrationSupport",
+}
+
+var lpaRSPCapabilities = []string{
+	"crlStaplingV3Support",
+	"certChainV3Support",
+	"signedSmdsResponseV3Support",
+	"euiccRspCapInInfo1",
+	"lpaProxySupport",
+	"enterpriseProfilesSupport",
+	"serviceDescriptionSupport",
+	"deviceChangeSupport",
+	"encryptedDeviceChangeDataSupport",
+	"estimatedProfileSizeIndicationSupport",
+	"profileSizeInProfilesInfoSupport",
+}
+
 type Version [3]byte
 
 func (v Version) MarshalJSON() ([]byte, error) {
 	return json.Marshal(v.String())
 }
 
+func (v *Version) UnmarshalJSON(data []byte) (err error) {
+	var value string
+	if err = json.Unmarshal(data, &value); err != nil {
+		return
+	}
+	*v, err = ParseVersion(value)
+	return
+}
+
+func ParseVersion(value string) (version Version, err error) {
+	_, err = fmt.Sscanf(value, "%d.%d.%d", &version[0], &version[1], &version[2])
+	if err != nil || value != version.String() {
+		err = fmt.Errorf("invalid version: %q", value)
+	}
+	return
+}
+
+func (v Version) Compare(other Version) int {
+	return bytes.Compare(v[:], other[:])
+}
+
 func (v Version) String() string {
 	return fmt.Sprintf("%d.%d.%d", v[0], v[1], v[2])
 }
@@ -0,0 +1,73 @@
+package dump
+
+import (
+	"encoding/hex"
+	"slices"
+	"testing"
+)
+
+func testKeyIds(keyIds ...string) (values []HexString) {
+	for _, keyId := range keyIds {
+		value, _ := hex.DecodeString(keyId)
+		values = append(values, value)
+	}
+	return
+}
+
+func TestEUICCInfo1Compare(t *testing.T) {
+	info1 := new(EUICCInfo1)
+	if err := info1.UnmarshalBerTLV(testInfo1(testKeyIdG1, testKeyIdTest)); err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		name  string
+		info2 EUICCInfo2
+		want  []string
+	}{
+		{
+			name: "equal",
+			info2: EUICCInfo2{
+				SVN:                Version{2, 2, 0},
+				IssuerVerification: testKeyIds(testKeyIdG1, testKeyIdTest),
+				IssuerSigning:      testKeyIds(testKeyIdG1, testKeyIdTest),
+			},
+		},
+		{
+			name: "mismatch",
+			info2: EUICCInfo2{
+				SVN:                Version{2, 3, 0},
+				IssuerVerification: testKeyIds(testKeyIdG1, testKeyIdTest),
+				IssuerSigning:      testKeyIds(testKeyIdG1),
+			},
+			want: []string{"svn", "euiccCiPKIdListForSigning"},
+		},
+		{
+			name: "order only",
+			info2: EUICCInfo2{
+				SVN:                Version{2, 2, 0},
+				IssuerVerification: testKeyIds(testKeyIdTest, testKeyIdG1),
+				IssuerSigning:      testKeyIds(testKeyIdTest, testKeyIdG1),
+			},
+		},
+		{
+			name: "same size, different key id",
+			info2: EUICCInfo2{
+				SVN:                Version{2, 2, 0},
+				IssuerVerification: testKeyIds(testKeyIdG1, testKeyIdTest),
+				IssuerSigning:      testKeyIds(testKeyIdG1, testKeyIdTestBRP),
+			},
+			want: []string{"euiccCiPKIdListForSigning"},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var fields []string
+			for _, mismatch := range info1.Compare(&c.info2) {
+				fields = append(fields, mismatch.Field)
+			}
+			if !slices.Equal(fields, c.want) {
+				t.Fatalf("mismatches = %v, want %v", fields, c.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package dump
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	. "github.com/CursedHardware/go-rsp-dump/rsp/types"
+	. "github.com/euicc-go/bertlv"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// DefaultUpstreamTimeout bounds each SM-DP+ call of a host without its own timeout
+const DefaultUpstreamTimeout = 30 * time.Second
+
+// DefaultFailoverTimeout and DefaultFailoverAttempts bound the hosts of an issuer
+// tried for one InitiateAuthentication request
+const (
+	DefaultFailoverTimeout  = 60 * time.Second
+	DefaultFailoverAttempts = 3
+)
+
+// CancelSessionResponse ::= [65] CHOICE { ..., cancelSessionResponseError [1] INTEGER { undefinedError(127) } },
+// the CHOICE has AUTOMATIC TAGS so the error alternative is [1] rather than the universal INTEGER
+var cancelSessionUndefinedError = NewChildren(Tag{0xBF, 0x41}, NewValue(Tag{0x81}, []byte{0x7F}))
+
+func (h *Handler) invoke(ctx context.Context, host, function, protocol string, request, response any) error {
+	return invoke(ctx, h.Client, host, function, protocol, request, response)
+}
+
+func invoke(ctx context.Context, client *http.Client, host, function, protocol string, request, response any) (err error) {
+	u := &url.URL{Scheme: "https", Host: host, Path: "/gsma/rsp2/es9plus/" + function}
+	body, _ := json.Marshal(request)
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), bytes.NewReader(body))
+	req.Header.Set("User-Agent", "gsma-rsp-lpad")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Admin-Protocol", protocol)
+	resp, err := client.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	return json.NewDecoder(resp.Body).Decode(response)
+}
+
+// cancelInBackground cancels the upstream session without holding up the answer
+// to the LPA, cancelUpstream bounds the call with its own DefaultUpstreamTimeout
+func (h *Handler) cancelInBackground(session *Session) {
+	h.cancels.Add(1)
+	go func() {
+		defer h.cancels.Done()
+		h.cancelUpstream(session, nil)
+	}()
+}
+
+// Wait blocks until the upstream sessions cancelled in the background are done
+func (h *Handler) Wait() {
+	h.cancels.Wait()
+}
+
+func (h *Handler) cancelUpstream(session *Session, response *TLV) {
+	if session == nil || session.Host == "" {
+		return
+	}
+	if response == nil {
+		response = cancelSessionUndefinedError
+	}
+	request := &CancelSessionRequest{TransactionId: session.TransactionId, Response: response}
+	var resp GeneralResponse
+	protocol := session.AdminProtocol
+	if protocol == "" {
+		protocol = negotiateProtocol("", session.Info1)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultUpstreamTimeout)
+	defer cancel()
+	err := h.invoke(ctx, session.Host, "cancelSession", protocol, request, &resp)
+	if err == nil {
+		err = resp.Header.Err()
+	}
+	if err != nil {
+		log.Println("ES9+.CancelSession", "TransactionId:", session.TransactionId, "Host:", session.Host, "Error:", err)
+	} else {
+		log.Println("ES9+.CancelSession", "TransactionId:", session.TransactionId, "Host:", session.Host)
+	}
+	if err = h.sessions().Delete(session.TransactionId); err != nil {
+		log.Println("ES9+.CancelSession", "TransactionId:", session.TransactionId, "Session:", err)
+	}
+}
@@ -2,29 +2,13 @@ package dump
 
 import (
 	"bytes"
-	"encoding/asn1"
 	"encoding/binary"
 	"encoding/pem"
 	"github.com/euicc-go/bertlv"
-	"os/exec"
-	"strings"
-)
-
-var (
-	certOpts     = []string{"ext_parse"}
-	certNameOpts = []string{"sep_multiline", "space_eq", "lname", "utf8"}
 )
 
 func parseCertificate(data []byte) (output []byte) {
-	cmd := exec.Command(
-		"openssl", "x509",
-		"-inform", "DER",
-		"-text",
-		"-certopt", strings.Join(certOpts, ","),
-		"-nameopt", strings.Join(certNameOpts, ","),
-	)
-	cmd.Stdin = bytes.NewReader(data)
-	if output, _ = cmd.Output(); output == nil {
+	if output, _ = renderCertificate(data); output == nil {
 		output = pem.EncodeToMemory(&pem.Block{
 			Type:  "CERTIFICATE",
 			Bytes: data,
@@ -39,15 +23,21 @@ func variant(b []byte) uint64 {
 	return binary.BigEndian.Uint64(dst)
 }
 
-func toBits(tlv *bertlv.TLV, definitions ...string) (features []string) {
-	bits := &asn1.BitString{
-		Bytes:     tlv.Value[1:],
-		BitLength: (len(tlv.Value)-1)*8 - int(tlv.Value[0]),
+func toKeyIds(tlv *bertlv.TLV) (keyIds []HexString) {
+	if tlv == nil {
+		return
 	}
-	for index := 0; index < min(bits.BitLength, len(definitions)); index++ {
-		if bits.At(index) == 1 {
-			features = append(features, definitions[index])
-		}
+	for _, child := range tlv.Children {
+		keyIds = append(keyIds, child.Value)
 	}
 	return
 }
+
+func hasKeyId(tlv *bertlv.TLV, keyId []byte) bool {
+	for _, candidate := range toKeyIds(tlv) {
+		if bytes.Equal(candidate, keyId) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,285 @@
+package dump
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	. "github.com/CursedHardware/go-rsp-dump/rsp/types"
+	. "github.com/euicc-go/bertlv"
+	"math/big"
+	"slices"
+	"strings"
+	"time"
+)
+
+// errUnknownCI is returned once everything but the chain to the CI has been verified
+var errUnknownCI = errors.New("CI unknown, serverCertificate chain is not verified")
+
+func verifyInitAuthen(r *InitAuthenRequest, resp *InitAuthenResponse, issuer []byte, ci *certificate, now time.Time) error {
+	if resp.Signed1 == nil || resp.Signature1 == nil || resp.Certificate == nil {
+		return errors.New("incomplete response")
+	}
+	if challenge := resp.Signed1.First(Tag{0x81}); challenge == nil || !bytes.Equal(challenge.Value, r.Challenge) {
+		return errors.New("euiccChallenge is mismatch")
+	}
+	if address := resp.Signed1.First(Tag{0x83}); address == nil || string(address.Value) != r.Address {
+		return errors.New("serverAddress is mismatch")
+	}
+	server, err := parseTLVCertificate(resp.Certificate)
+	if err != nil {
+		return err
+	}
+	intermediates := make([]*certificate, 0, len(resp.OtherCertsInChain))
+	for index, tlv := range resp.OtherCertsInChain {
+		intermediate, err := parseTLVCertificate(tlv)
+		if err != nil {
+			return fmt.Errorf("otherCertsInChain[%d]: %w", index, err)
+		}
+		intermediates = append(intermediates, intermediate)
+	}
+	if err = verifyServerChain(server, intermediates, issuer, ci, now); err != nil {
+		return err
+	}
+	publicKey, err := server.publicKey()
+	if err != nil {
+		return err
+	}
+	signed, err := resp.Signed1.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	if err = verifySignature(publicKey, signed, resp.Signature1.Value); err != nil {
+		return err
+	}
+	if ci == nil {
+		return errUnknownCI
+	}
+	return nil
+}
+
+// verifyServerChain walks from serverCertificate through the SubCA certificates of
+// otherCertsInChain (SGP.22 v3) up to the certificate issued by the requested CI,
+// without the CI the chain is only checked below it
+func verifyServerChain(leaf *certificate, intermediates []*certificate, issuer []byte, ci *certificate, now time.Time) error {
+	current, name := leaf, "serverCertificate"
+	for range len(intermediates) + 1 {
+		if err := current.checkValidity(now); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		if bytes.Equal(current.authorityKeyId(), issuer) {
+			if ci == nil {
+				return nil
+			}
+			return verifySignedBy(name, current, ci)
+		}
+		index := slices.IndexFunc(intermediates, func(intermediate *certificate) bool {
+			return bytes.Equal(intermediate.subjectKeyId(), current.authorityKeyId())
+		})
+		if index < 0 {
+			break
+		}
+		if err := verifySignedBy(name, current, intermediates[index]); err != nil {
+			return err
+		}
+		current, name = intermediates[index], fmt.Sprintf("otherCertsInChain[%d]", index)
+	}
+	return errors.New("serverCertificate is not issued by the requested CI")
+}
+
+func verifySignedBy(name string, child, parent *certificate) error {
+	publicKey, err := parent.publicKey()
+	if err != nil {
+		return err
+	}
+	if err = child.checkSignatureFrom(publicKey); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return nil
+}
+
+// the signature is the raw r || s concatenation, see SGP.22 section 2.6.7
+func verifySignature(publicKey any, data, signature []byte) error {
+	if len(signature) == 0 || len(signature)%2 != 0 {
+		return errors.New("malformed signature")
+	}
+	digest := sha256.Sum256(data)
+	size := len(signature) / 2
+	r := new(big.Int).SetBytes(signature[:size])
+	s := new(big.Int).SetBytes(signature[size:])
+	return verifyECDSA(publicKey, digest[:], r, s)
+}
+
+func verifyECDSA(publicKey any, digest []byte, r, s *big.Int) error {
+	var valid bool
+	switch key := publicKey.(type) {
+	case *ecdsa.PublicKey:
+		valid = ecdsa.Verify(key, digest, r, s)
+	case *weierstrassKey:
+		valid = key.verify(digest, r, s)
+	default:
+		return errors.New("unsupported public key")
+	}
+	if !valid {
+		return errors.New("invalid signature")
+	}
+	return nil
+}
+
+const (
+	VerificationValid     = "valid"
+	VerificationInvalid   = "invalid"
+	VerificationUnknownCI = "unknown-ci"
+)
+
+// Verification is the outcome of Report.Verify, Status is VerificationInvalid
+// as soon as one check fails and VerificationUnknownCI when the CI is not in the bundle.
+type Verification struct {
+	Status string              `json:"status"`
+	CI     HexString           `json:"ci,omitempty"`
+	Checks []VerificationCheck `json:"checks"`
+}
+
+type VerificationCheck struct {
+	Name   string `json:"name"`
+	Passed bool   `json:"passed"`
+	Reason string `json:"reason,omitempty"`
+}
+
+func (v *Verification) check(name string, err error) bool {
+	check := VerificationCheck{Name: name, Passed: err == nil}
+	if err != nil {
+		check.Reason = err.Error()
+		v.Status = VerificationInvalid
+	}
+	v.Checks = append(v.Checks, check)
+	return err == nil
+}
+
+// Verify checks euiccSignature1 with the eUICC certificate, the eUICC → EUM → CI chain
+// against the bundle and the EIN name constraints of the EUM certificate.
+func (r *Report) Verify(bundle CIBundle) {
+	v := &Verification{Status: VerificationValid}
+	r.Verification = v
+	now := time.Now()
+	euicc, err := parseTLVCertificate(r.EUICCCertificate)
+	if !v.check("euiccCertificate", err) {
+		return
+	}
+	eum, err := parseTLVCertificate(r.EUMCertificate)
+	if !v.check("eumCertificate", err) {
+		return
+	}
+	v.check("euiccSignature1", verifySigned1(euicc, r.EUICCSigned1, r.EUICCSignature1))
+	v.check("euiccCertificateSignature", verifyIssued(euicc, eum, now))
+	v.check("nameConstraints", verifyNameConstraints(euicc, eum))
+	v.CI = eum.authorityKeyId()
+	ci := bundle.lookup(v.CI)
+	if ci == nil {
+		if v.Status == VerificationValid {
+			v.Status = VerificationUnknownCI
+		}
+		return
+	}
+	if v.check("eumCertificateSignature", verifyIssued(eum, ci, now)) {
+		v.check("ciCertificate", ci.checkValidity(now))
+	}
+}
+
+func parseTLVCertificate(tlv *TLV) (*certificate, error) {
+	if tlv == nil {
+		return nil, errors.New("missing")
+	}
+	data, err := tlv.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return parseRawCertificate(data)
+}
+
+func verifySigned1(euicc *certificate, signed1, signature1 *TLV) error {
+	if signed1 == nil || signature1 == nil {
+		return errors.New("missing euiccSigned1 or euiccSignature1")
+	}
+	publicKey, err := euicc.publicKey()
+	if err != nil {
+		return err
+	}
+	signed, err := signed1.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	return verifySignature(publicKey, signed, signature1.Value)
+}
+
+func verifyIssued(child, parent *certificate, now time.Time) error {
+	if !bytes.Equal(child.authorityKeyId(), parent.subjectKeyId()) {
+		return errors.New("authority key id does not match the issuer subject key id")
+	}
+	publicKey, err := parent.publicKey()
+	if err != nil {
+		return err
+	}
+	if err = child.checkSignatureFrom(publicKey); err != nil {
+		return err
+	}
+	return child.checkValidity(now)
+}
+
+// verifyNameConstraints applies the permitted subtrees of the EUM certificate to the eUICC subject,
+// SGP.22 restricts the organization and the EIN prefix of the EID (serialNumber)
+func verifyNameConstraints(euicc, eum *certificate) error {
+	permitted, err := eum.permittedNames()
+	if err != nil {
+		return err
+	}
+	if len(permitted) == 0 {
+		return errors.New("EUM certificate has no permitted subtrees")
+	}
+	subject, err := euicc.subject()
+	if err != nil {
+		return err
+	}
+	for _, name := range permitted {
+		if matchNameConstraint(subject, name) {
+			return nil
+		}
+	}
+	return fmt.Errorf("EID %s is outside of the EINs permitted by the EUM", rdnValue(subject, oidSerialNumber))
+}
+
+var oidSerialNumber = asn1.ObjectIdentifier{2, 5, 4, 5}
+
+func matchNameConstraint(subject, permitted pkix.RDNSequence) bool {
+	for _, set := range permitted {
+		for _, attribute := range set {
+			value, ok := attribute.Value.(string)
+			if !ok {
+				return false
+			}
+			switch {
+			case attribute.Type.Equal(oidSerialNumber):
+				if !strings.HasPrefix(rdnValue(subject, oidSerialNumber), value) {
+					return false
+				}
+			case rdnValue(subject, attribute.Type) != value:
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func rdnValue(name pkix.RDNSequence, oid asn1.ObjectIdentifier) string {
+	for _, set := range name {
+		for _, attribute := range set {
+			if value, ok := attribute.Value.(string); ok && attribute.Type.Equal(oid) {
+				return value
+			}
+		}
+	}
+	return ""
+}
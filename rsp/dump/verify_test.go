@@ -0,0 +1,290 @@
+package dump
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/hex"
+	"errors"
+	. "github.com/CursedHardware/go-rsp-dump/rsp/types"
+	. "github.com/euicc-go/bertlv"
+	"math/big"
+	"testing"
+	"time"
+)
+
+type testIssuer struct {
+	key         *ecdsa.PrivateKey
+	template    *x509.Certificate
+	raw         []byte
+	certificate *certificate
+}
+
+// newTestIssuer creates a certificate from template, self-signed when parent is nil
+func newTestIssuer(t *testing.T, parent *testIssuer, template *x509.Certificate) *testIssuer {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if template.SerialNumber == nil {
+		template.SerialNumber = big.NewInt(1)
+	}
+	issuer := &testIssuer{key: key, template: template}
+	signer, signerTemplate := key, template
+	if parent != nil {
+		signer, signerTemplate = parent.key, parent.template
+	}
+	if issuer.raw, err = x509.CreateCertificate(rand.Reader, template, signerTemplate, &key.PublicKey, signer); err != nil {
+		t.Fatal(err)
+	}
+	if issuer.certificate, err = parseRawCertificate(issuer.raw); err != nil {
+		t.Fatal(err)
+	}
+	return issuer
+}
+
+// sign returns the raw r || s signature of SGP.22 section 2.6.7
+func (i *testIssuer) sign(t *testing.T, data []byte) []byte {
+	t.Helper()
+	digest := sha256.Sum256(data)
+	r, s, err := ecdsa.Sign(rand.Reader, i.key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	s.FillBytes(signature[32:])
+	return signature
+}
+
+func (i *testIssuer) tlv(t *testing.T) *TLV {
+	t.Helper()
+	tlv := new(TLV)
+	if err := tlv.UnmarshalBinary(i.raw); err != nil {
+		t.Fatal(err)
+	}
+	return tlv
+}
+
+// testNameConstraints permits the directoryName of organization and EIN, as in the EUM certificates of SGP.22
+func testNameConstraints(t *testing.T, organization, ein string) pkix.Extension {
+	t.Helper()
+	name, err := asn1.Marshal(pkix.Name{Organization: []string{organization}, SerialNumber: ein}.ToRDNSequence())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var constraints struct {
+		Permitted []struct{ Base asn1.RawValue } `asn1:"optional,tag:0"`
+	}
+	constraints.Permitted = append(constraints.Permitted, struct{ Base asn1.RawValue }{
+		asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 4, IsCompound: true, Bytes: name},
+	})
+	value, err := asn1.Marshal(constraints)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pkix.Extension{Id: asn1.ObjectIdentifier{2, 5, 29, 30}, Critical: true, Value: value}
+}
+
+type testEUICCChain struct {
+	eum, euicc *testIssuer
+}
+
+func newTestCI(t *testing.T) *testIssuer {
+	t.Helper()
+	now := time.Now()
+	return newTestIssuer(t, nil, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "Test CI"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(time.Hour),
+		SubjectKeyId:          []byte("ci"),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	})
+}
+
+// newTestEUICCChain issues an EUM certificate permitting the EIN 89049032 and the eUICC certificate of eid
+func newTestEUICCChain(t *testing.T, ci *testIssuer, eid string) *testEUICCChain {
+	t.Helper()
+	now := time.Now()
+	eum := newTestIssuer(t, ci, &x509.Certificate{
+		Subject:               pkix.Name{Organization: []string{"Test EUM"}, CommonName: "Test EUM"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(time.Hour),
+		SubjectKeyId:          []byte("eum"),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		ExtraExtensions:       []pkix.Extension{testNameConstraints(t, "Test EUM", "89049032")},
+	})
+	euicc := newTestIssuer(t, eum, &x509.Certificate{
+		Subject:      pkix.Name{Organization: []string{"Test EUM"}, SerialNumber: eid},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(time.Hour),
+		SubjectKeyId: []byte("euicc"),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	})
+	return &testEUICCChain{eum: eum, euicc: euicc}
+}
+
+// report answers an AuthenticateServer with euiccSigned1 signed by the eUICC
+func (c *testEUICCChain) report(t *testing.T) *Report {
+	t.Helper()
+	signed1 := NewChildren(
+		Tag{0x30},
+		NewValue(Tag{0x80}, []byte{0x01}),
+		NewValue(Tag{0x83}, []byte("smdp.example.com")),
+		NewValue(Tag{0x84}, make([]byte, 16)),
+	)
+	data, _ := signed1.MarshalBinary()
+	return &Report{
+		EUICCSigned1:     signed1,
+		EUICCSignature1:  NewValue(Tag{0x5F, 0x37}, c.euicc.sign(t, data)),
+		EUICCCertificate: c.euicc.tlv(t),
+		EUMCertificate:   c.eum.tlv(t),
+	}
+}
+
+func TestReportVerify(t *testing.T) {
+	ci := newTestCI(t)
+	bundle := CIBundle{hex.EncodeToString(ci.certificate.subjectKeyId()): ci.certificate}
+	valid := newTestEUICCChain(t, ci, "89049032123451234512345678901235")
+
+	cases := []struct {
+		name   string
+		report *Report
+		bundle CIBundle
+		status string
+		failed string
+	}{
+		{"valid chain", valid.report(t), bundle, VerificationValid, ""},
+		{"unknown CI", valid.report(t), CIBundle{}, VerificationUnknownCI, ""},
+		{"EID outside the EUM constraints", newTestEUICCChain(t, ci, "89086030202200000024000000000001").report(t), bundle, VerificationInvalid, "nameConstraints"},
+		{"tampered euiccSignature1", func() *Report {
+			report := valid.report(t)
+			report.EUICCSignature1.Value[10] ^= 0xFF
+			return report
+		}(), bundle, VerificationInvalid, "euiccSignature1"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			c.report.Verify(c.bundle)
+			verification := c.report.Verification
+			if verification.Status != c.status {
+				t.Fatalf("status = %s, want %s: %+v", verification.Status, c.status, verification.Checks)
+			}
+			for _, check := range verification.Checks {
+				if check.Passed == (check.Name == c.failed) {
+					t.Fatalf("check %s passed = %t: %s", check.Name, check.Passed, check.Reason)
+				}
+			}
+		})
+	}
+}
+
+func newTestInitAuthen(t *testing.T, server *testIssuer, request *InitAuthenRequest) *InitAuthenResponse {
+	t.Helper()
+	signed1 := NewChildren(
+		Tag{0x30},
+		NewValue(Tag{0x80}, []byte{0x01}),
+		NewValue(Tag{0x81}, request.Challenge),
+		NewValue(Tag{0x83}, []byte(request.Address)),
+		NewValue(Tag{0x84}, make([]byte, 16)),
+	)
+	data, _ := signed1.MarshalBinary()
+	return &InitAuthenResponse{
+		TransactionId: "01",
+		Signed1:       signed1,
+		Signature1:    NewValue(Tag{0x5F, 0x37}, server.sign(t, data)),
+		UsedIssuer:    NewValue(Tag{0x04}, server.certificate.authorityKeyId()),
+		Certificate:   server.tlv(t),
+	}
+}
+
+func TestVerifyInitAuthen(t *testing.T) {
+	now := time.Now()
+	server := func(notBefore, notAfter time.Time) *x509.Certificate {
+		return &x509.Certificate{
+			Subject:      pkix.Name{CommonName: "smdp.example.com"},
+			NotBefore:    notBefore,
+			NotAfter:     notAfter,
+			SubjectKeyId: []byte("server"),
+			KeyUsage:     x509.KeyUsageDigitalSignature,
+		}
+	}
+	ci := newTestCI(t)
+	valid := newTestIssuer(t, ci, server(now.Add(-time.Hour), now.Add(time.Hour)))
+	expired := newTestIssuer(t, ci, server(now.Add(-2*time.Hour), now.Add(-time.Hour)))
+	request := &InitAuthenRequest{Challenge: make([]byte, 16), Address: "smdp.example.com"}
+	issuer := ci.certificate.subjectKeyId()
+
+	if err := verifyInitAuthen(request, newTestInitAuthen(t, valid, request), issuer, ci.certificate, now); err != nil {
+		t.Fatalf("valid response: %v", err)
+	}
+	if err := verifyInitAuthen(request, newTestInitAuthen(t, valid, request), issuer, nil, now); !errors.Is(err, errUnknownCI) {
+		t.Fatalf("unknown CI: %v, want %v", err, errUnknownCI)
+	}
+	if err := verifyInitAuthen(request, newTestInitAuthen(t, expired, request), issuer, ci.certificate, now); err == nil {
+		t.Fatal("expired serverCertificate is accepted")
+	}
+	tampered := newTestInitAuthen(t, valid, request)
+	tampered.Signature1.Value[0] ^= 0xFF
+	if err := verifyInitAuthen(request, tampered, issuer, ci.certificate, now); err == nil {
+		t.Fatal("tampered serverSignature1 is accepted")
+	}
+}
+
+func TestVerifyInitAuthenSubCA(t *testing.T) {
+	now := time.Now()
+	ci := newTestCI(t)
+	subCA := newTestIssuer(t, ci, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "Test SM-DP+ SubCA"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(time.Hour),
+		SubjectKeyId:          []byte("subca"),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	})
+	server := newTestIssuer(t, subCA, &x509.Certificate{
+		Subject:      pkix.Name{CommonName: "smdp.example.com"},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(time.Hour),
+		SubjectKeyId: []byte("server"),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	})
+	request := &InitAuthenRequest{Challenge: make([]byte, 16), Address: "smdp.example.com"}
+	issuer := ci.certificate.subjectKeyId()
+	response := func(chain ...*testIssuer) *InitAuthenResponse {
+		resp := newTestInitAuthen(t, server, request)
+		for _, certificate := range chain {
+			resp.OtherCertsInChain = append(resp.OtherCertsInChain, certificate.tlv(t))
+		}
+		return resp
+	}
+
+	if err := verifyInitAuthen(request, response(subCA), issuer, ci.certificate, now); err != nil {
+		t.Fatalf("SubCA chain: %v", err)
+	}
+	if err := verifyInitAuthen(request, response(subCA), issuer, nil, now); !errors.Is(err, errUnknownCI) {
+		t.Fatalf("SubCA chain without the CI: %v, want %v", err, errUnknownCI)
+	}
+	if err := verifyInitAuthen(request, response(), issuer, ci.certificate, now); err == nil {
+		t.Fatal("serverCertificate without its SubCA is accepted")
+	}
+	forged := newTestIssuer(t, nil, &x509.Certificate{
+		Subject:      pkix.Name{CommonName: "Forged SubCA"},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(time.Hour),
+		SubjectKeyId: []byte("subca"),
+	})
+	if err := verifyInitAuthen(request, response(forged), issuer, ci.certificate, now); err == nil {
+		t.Fatal("forged SubCA is accepted")
+	}
+}
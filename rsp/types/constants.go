@@ -10,12 +10,14 @@ const (
 )
 
 func (s Status) Header(err error) Header {
-	data := &StatusCodeData{
-		Status:      s,
-		SubjectCode: "1.1",
-		ReasonCode:  "1.1",
-		Message:     err.Error(),
+	status := FunctionExecutionStatus{Status: s}
+	if err != nil {
+		status.StatusCodeData = &StatusCodeData{
+			Status:      s,
+			SubjectCode: "1.1",
+			ReasonCode:  "1.1",
+			Message:     err.Error(),
+		}
 	}
-	status := FunctionExecutionStatus{StatusCodeData: data}
 	return Header{FunctionExecutionStatus: status}
 }
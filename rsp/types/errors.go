@@ -8,15 +8,20 @@ func (e *Error) Error() string {
 	return e.Message
 }
 
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && e.SubjectCode == t.SubjectCode && e.ReasonCode == t.ReasonCode
+}
+
 func (e *Error) MarshalJSON() ([]byte, error) {
-	data := StatusCodeData{Status: ExecutedSuccess}
-	if e != nil {
-		data = StatusCodeData(*e)
+	if e == nil {
+		return json.Marshal(&GeneralResponse{Header: ExecutedSuccess.Header(nil)})
 	}
-	if e.Status == "" {
+	data := StatusCodeData(*e)
+	if data.Status == "" {
 		data.Status = Failed
 	}
-	status := FunctionExecutionStatus{StatusCodeData: &data}
+	status := FunctionExecutionStatus{Status: data.Status, StatusCodeData: &data}
 	header := Header{FunctionExecutionStatus: status}
 	return json.Marshal(&GeneralResponse{Header: header})
 }
@@ -6,12 +6,29 @@ type Header struct {
 	FunctionExecutionStatus FunctionExecutionStatus `json:"functionExecutionStatus"`
 }
 
+func (h *Header) Err() error {
+	status := h.FunctionExecutionStatus
+	if status.Status == ExecutedSuccess || status.Status == ExecutedWithWarning {
+		return nil
+	}
+	data := StatusCodeData{SubjectCode: "1.1", ReasonCode: "1.1", Message: "unknown function execution status"}
+	if status.StatusCodeData != nil {
+		data = *status.StatusCodeData
+	}
+	data.Status = status.Status
+	if data.Status == "" {
+		data.Status = Failed
+	}
+	return (*Error)(&data)
+}
+
 type FunctionExecutionStatus struct {
-	StatusCodeData *StatusCodeData `json:"statusCodeData"`
+	Status         Status          `json:"status"`
+	StatusCodeData *StatusCodeData `json:"statusCodeData,omitempty"`
 }
 
 type StatusCodeData struct {
-	Status            Status `json:"status,omitempty"`
+	Status            Status `json:"-"`
 	SubjectCode       string `json:"subjectCode"`
 	SubjectIdentifier string `json:"subjectIdentifier,omitempty"`
 	ReasonCode        string `json:"reasonCode"`
@@ -19,8 +36,5 @@ type StatusCodeData struct {
 }
 
 func (d *StatusCodeData) MarshalJSON() ([]byte, error) {
-	if d == nil {
-		return json.Marshal(Error{Status: ExecutedSuccess})
-	}
 	return json.Marshal(Error(*d))
 }
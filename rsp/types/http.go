@@ -7,21 +7,37 @@ type GeneralResponse struct {
 }
 
 type InitAuthenRequest struct {
-	Challenge []byte `json:"euiccChallenge"`
-	Address   string `json:"smdpAddress"`
-	Info1     *TLV   `json:"euiccInfo1"`
+	Challenge        []byte `json:"euiccChallenge"`
+	Address          string `json:"smdpAddress"`
+	Info1            *TLV   `json:"euiccInfo1"`
+	LPARSPCapability *TLV   `json:"lpaRspCapability,omitempty"`
+	AdminProtocol    string `json:"-"`
+}
+
+type SMDSInitAuthenRequest struct {
+	Challenge        []byte `json:"euiccChallenge"`
+	Address          string `json:"smdsAddress"`
+	Info1            *TLV   `json:"euiccInfo1"`
+	LPARSPCapability *TLV   `json:"lpaRspCapability,omitempty"`
+	AdminProtocol    string `json:"-"`
 }
 
 type InitAuthenResponse struct {
-	Header        Header `json:"header"`
-	TransactionId string `json:"transactionId"`
-	Signed1       *TLV   `json:"serverSigned1"`
-	Signature1    *TLV   `json:"serverSignature1"`
-	UsedIssuer    *TLV   `json:"euiccCiPKIdToBeUsed"`
-	Certificate   *TLV   `json:"serverCertificate"`
+	Header            Header `json:"header"`
+	TransactionId     string `json:"transactionId"`
+	Signed1           *TLV   `json:"serverSigned1"`
+	Signature1        *TLV   `json:"serverSignature1"`
+	UsedIssuer        *TLV   `json:"euiccCiPKIdToBeUsed"`
+	Certificate       *TLV   `json:"serverCertificate"`
+	OtherCertsInChain []*TLV `json:"otherCertsInChain,omitempty"` // SGP.22 v3, the SubCA certificates up to the CI
 }
 
 type AuthenClientRequest struct {
 	TransactionId string `json:"transactionId"`
 	Response      *TLV   `json:"authenticateServerResponse"`
 }
+
+type CancelSessionRequest struct {
+	TransactionId string `json:"transactionId"`
+	Response      *TLV   `json:"cancelSessionResponse"`
+}